@@ -1,35 +1,63 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/barcek2281/comics-store/api-gateway/internal/configs"
 	"github.com/barcek2281/comics-store/api-gateway/internal/server"
+	"github.com/barcek2281/comics-store/pkg/requestid"
 )
 
 var (
-	configPath string
+	configPath  string
+	printConfig bool
 )
 
 func init() {
 	flag.StringVar(&configPath, "config-path", "./configs/local.yaml", "config path")
+	flag.BoolVar(&printConfig, "print-config", false, "print the effective config with secrets redacted and exit")
 }
 func main() {
 	flag.Parse()
 
 	cfg := configs.MustLoad(configPath)
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot print config: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	level, _ := cfg.Level()
+	log := slog.New(requestid.NewHandler(
+		slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}),
+	))
+	slog.SetDefault(log)
 
-	log := slog.New(
-		slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-	)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	clientIPs, err := cfg.ClientIPs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "trusted_proxies: %v\n", err)
+		os.Exit(1)
+	}
 
-	s := server.NewServer(log, cfg.Port)
+	s := server.NewServer(log, cfg, clientIPs)
 	slog.Info("server starting", "port", cfg.Port)
-	if err := s.Run(); err != nil {
-		fmt.Printf("cannot start a server")
+	if err := s.Run(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("cannot start a server: %v\n", err)
+		os.Exit(1)
 	}
+	slog.Info("server stopped")
 
 }
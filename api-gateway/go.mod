@@ -3,6 +3,8 @@ module github.com/barcek2281/comics-store/api-gateway
 go 1.22.2
 
 require (
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/barcek2281/comics-store/pkg v0.0.0-00010101000000-000000000000
 	github.com/barcek2281/proto-comics v0.0.0-20250412081600-6c052819b37e
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/ilyakaznacheev/cleanenv v1.5.0
@@ -16,14 +18,19 @@ require (
 
 require (
 	github.com/BurntSushi/toml v1.5.0 // indirect
-	github.com/barcek2281/proto v0.0.0-20250412082746-8e5f3c47245f
+	github.com/barcek2281/proto v0.0.0-00010101000000-000000000000
 	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f
 	google.golang.org/protobuf v1.36.6 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 	olympos.io/encoding/edn v0.0.0-20201019073823-d3554ca0b0a3 // indirect
 )
+
+replace github.com/barcek2281/proto => ../proto
+
+replace github.com/barcek2281/comics-store/pkg => ../pkg
@@ -2,9 +2,10 @@ package cache
 
 import "github.com/go-redis/redis/v8"
 
-func NewRedisClient() *redis.Client {
-    return redis.NewClient(&redis.Options{
-        Addr: "redis:6379",
-        DB:   0,
-    })
-}
\ No newline at end of file
+func NewRedisClient(addr, password string, db int) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+}
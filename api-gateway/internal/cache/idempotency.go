@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// StoredResponse is what an idempotency key maps to: the fingerprint of
+// the request that claimed it and, once that request finished, its
+// response.
+type StoredResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Done        bool        `json:"done"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyStore remembers responses by idempotency key in Redis. A key
+// is claimed for lockTTL while its first request runs and keeps the
+// response for ttl afterwards.
+type IdempotencyStore struct {
+	client  *redis.Client
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+func NewIdempotencyStore(client *redis.Client, ttl, lockTTL time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{client: client, ttl: ttl, lockTTL: lockTTL}
+}
+
+// Begin claims key for a request with the given fingerprint. It returns
+// the stored response when the key already completed with the same
+// fingerprint, ErrIdempotencyKeyReused when the fingerprint differs and
+// ErrIdempotencyInProgress while the first request is still running. A
+// nil response and error means the caller owns the key and must call
+// Complete or Release.
+func (s *IdempotencyStore) Begin(ctx context.Context, key, fingerprint string) (*StoredResponse, error) {
+	pending, err := json.Marshal(StoredResponse{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := s.client.SetNX(ctx, idempotencyKey(key), pending, s.lockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	raw, err := s.client.Get(ctx, idempotencyKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// The claim expired in between; let the client retry.
+		return nil, ErrIdempotencyInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored StoredResponse
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil, err
+	}
+	switch {
+	case stored.Fingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyReused
+	case !stored.Done:
+		return nil, ErrIdempotencyInProgress
+	}
+	return &stored, nil
+}
+
+// Complete stores the response of the request that claimed key.
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, res StoredResponse) error {
+	res.Done = true
+	raw, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, idempotencyKey(key), raw, s.ttl).Err()
+}
+
+// Release gives up a claim so the request can be retried with the same key.
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, idempotencyKey(key)).Err()
+}
+
+func idempotencyKey(key string) string {
+	return "idempotency:" + key
+}
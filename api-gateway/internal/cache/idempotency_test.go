@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newIdempotencyStore(t *testing.T) (*IdempotencyStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewIdempotencyStore(client, time.Hour, time.Minute), mr
+}
+
+func TestIdempotencyStoreReplay(t *testing.T) {
+	s, _ := newIdempotencyStore(t)
+	ctx := context.Background()
+
+	res, err := s.Begin(ctx, "k", "fp")
+	if res != nil || err != nil {
+		t.Fatalf("first Begin = (%v, %v), want claim", res, err)
+	}
+	if _, err := s.Begin(ctx, "k", "fp"); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Fatalf("Begin while running = %v, want ErrIdempotencyInProgress", err)
+	}
+
+	stored := StoredResponse{
+		Fingerprint: "fp",
+		Status:      http.StatusCreated,
+		Header:      http.Header{"Content-Type": {"application/json"}},
+		Body:        []byte(`{"id":1}`),
+	}
+	if err := s.Complete(ctx, "k", stored); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = s.Begin(ctx, "k", "fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res == nil || !res.Done || res.Status != http.StatusCreated || string(res.Body) != `{"id":1}` ||
+		res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("replayed %+v, want the stored response", res)
+	}
+
+	if _, err := s.Begin(ctx, "k", "other"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Begin with another fingerprint = %v, want ErrIdempotencyKeyReused", err)
+	}
+}
+
+func TestIdempotencyStoreRelease(t *testing.T) {
+	s, _ := newIdempotencyStore(t)
+	ctx := context.Background()
+
+	if _, err := s.Begin(ctx, "k", "fp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Begin(ctx, "k", "other")
+	if res != nil || err != nil {
+		t.Errorf("Begin after Release = (%v, %v), want claim", res, err)
+	}
+}
+
+func TestIdempotencyStoreExpiry(t *testing.T) {
+	s, mr := newIdempotencyStore(t)
+	ctx := context.Background()
+
+	if _, err := s.Begin(ctx, "claim", "fp"); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Minute)
+	if res, err := s.Begin(ctx, "claim", "fp"); res != nil || err != nil {
+		t.Errorf("Begin after the claim expired = (%v, %v), want claim", res, err)
+	}
+
+	if err := s.Complete(ctx, "claim", StoredResponse{Fingerprint: "fp", Status: http.StatusOK}); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Minute)
+	if res, err := s.Begin(ctx, "claim", "fp"); err != nil || res == nil {
+		t.Errorf("Begin before the response expired = (%v, %v), want replay", res, err)
+	}
+	mr.FastForward(time.Hour)
+	if res, err := s.Begin(ctx, "claim", "other"); res != nil || err != nil {
+		t.Errorf("Begin after the response expired = (%v, %v), want claim", res, err)
+	}
+}
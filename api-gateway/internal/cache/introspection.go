@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+)
+
+const introspectionTTL = time.Second * 30
+
+type introspection struct {
+	res       *authv1.IntrospectResponse
+	expiresAt time.Time
+}
+
+type apiKeyIntrospection struct {
+	res       *authv1.IntrospectAPIKeyResponse
+	expiresAt time.Time
+}
+
+// IntrospectionCache memoizes auth.Introspect and auth.IntrospectAPIKey
+// results in memory, keyed by the credential hash so raw tokens and keys
+// are never kept around.
+type IntrospectionCache struct {
+	client authv1.AuthClient
+
+	mu      sync.Mutex
+	entries map[string]introspection
+	apiKeys map[string]apiKeyIntrospection
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewIntrospectionCache(client authv1.AuthClient) *IntrospectionCache {
+	c := &IntrospectionCache{
+		client:  client,
+		entries: make(map[string]introspection),
+		apiKeys: make(map[string]apiKeyIntrospection),
+		stop:    make(chan struct{}),
+	}
+	go c.evictLoop()
+	return c
+}
+
+// Close stops evicting expired results.
+func (c *IntrospectionCache) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	return nil
+}
+
+func (c *IntrospectionCache) Introspect(ctx context.Context, token string) (*authv1.IntrospectResponse, error) {
+	key := tokenHash(token)
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.res, nil
+	}
+
+	res, err := c.client.Introspect(ctx, &authv1.IntrospectRequest{Token: token})
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(introspectionTTL)
+	if exp := time.Unix(res.ExpiresAt, 0); res.ExpiresAt > 0 && exp.Before(expiresAt) {
+		expiresAt = exp
+	}
+
+	c.mu.Lock()
+	c.entries[key] = introspection{res: res, expiresAt: expiresAt}
+	c.mu.Unlock()
+
+	return res, nil
+}
+
+func (c *IntrospectionCache) IntrospectAPIKey(ctx context.Context, key string) (*authv1.IntrospectAPIKeyResponse, error) {
+	hash := tokenHash(key)
+
+	c.mu.Lock()
+	e, ok := c.apiKeys[hash]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.res, nil
+	}
+
+	res, err := c.client.IntrospectAPIKey(ctx, &authv1.IntrospectAPIKeyRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.apiKeys[hash] = apiKeyIntrospection{res: res, expiresAt: time.Now().Add(introspectionTTL)}
+	c.mu.Unlock()
+
+	return res, nil
+}
+
+// Forget drops a cached result, e.g. right after the token was revoked.
+func (c *IntrospectionCache) Forget(token string) {
+	c.mu.Lock()
+	delete(c.entries, tokenHash(token))
+	c.mu.Unlock()
+}
+
+// ForgetUser drops the cached results of every token of the user, e.g.
+// after the account was disabled.
+func (c *IntrospectionCache) ForgetUser(userID int64) {
+	c.mu.Lock()
+	for k, e := range c.entries {
+		if e.res.UserId == userID {
+			delete(c.entries, k)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// ForgetSession drops the cached results of every token of the session,
+// e.g. after the user signed it out.
+func (c *IntrospectionCache) ForgetSession(sessionID string) {
+	c.mu.Lock()
+	for k, e := range c.entries {
+		if e.res.SessionId == sessionID {
+			delete(c.entries, k)
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *IntrospectionCache) evictLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		c.mu.Lock()
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		for k, e := range c.apiKeys {
+			if now.After(e.expiresAt) {
+				delete(c.apiKeys, k)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
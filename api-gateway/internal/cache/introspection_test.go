@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc"
+)
+
+// countingAuth reports every token as active for user 1 and counts calls.
+type countingAuth struct {
+	authv1.AuthClient
+	calls map[string]int
+}
+
+func (c *countingAuth) Introspect(ctx context.Context, in *authv1.IntrospectRequest, opts ...grpc.CallOption) (*authv1.IntrospectResponse, error) {
+	c.calls[in.Token]++
+	return &authv1.IntrospectResponse{Active: true, UserId: 1, SessionId: "s-" + in.Token}, nil
+}
+
+func newIntrospectionCache(t *testing.T) (*IntrospectionCache, *countingAuth) {
+	t.Helper()
+	client := &countingAuth{calls: make(map[string]int)}
+	c := NewIntrospectionCache(client)
+	t.Cleanup(func() { c.Close() })
+	return c, client
+}
+
+func TestIntrospectionCacheForget(t *testing.T) {
+	c, client := newIntrospectionCache(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Introspect(ctx, "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.calls["a"] != 1 {
+		t.Fatalf("auth called %d times for a cached token, want 1", client.calls["a"])
+	}
+
+	c.Forget("a")
+	if _, err := c.Introspect(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls["a"] != 2 {
+		t.Errorf("auth called %d times after Forget, want 2", client.calls["a"])
+	}
+}
+
+func TestIntrospectionCacheForgetUser(t *testing.T) {
+	c, client := newIntrospectionCache(t)
+	ctx := context.Background()
+
+	for _, token := range []string{"a", "b"} {
+		if _, err := c.Introspect(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.ForgetUser(2)
+	c.ForgetUser(1)
+	for _, token := range []string{"a", "b"} {
+		if _, err := c.Introspect(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+		if client.calls[token] != 2 {
+			t.Errorf("auth called %d times for %s after ForgetUser, want 2", client.calls[token], token)
+		}
+	}
+}
+
+func TestIntrospectionCacheForgetSession(t *testing.T) {
+	c, client := newIntrospectionCache(t)
+	ctx := context.Background()
+
+	for _, token := range []string{"a", "b"} {
+		if _, err := c.Introspect(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.ForgetSession("s-a")
+	for _, token := range []string{"a", "b"} {
+		if _, err := c.Introspect(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.calls["a"] != 2 || client.calls["b"] != 1 {
+		t.Errorf("auth calls = %v after ForgetSession(s-a), want a:2 b:1", client.calls)
+	}
+}
+
+func TestIntrospectionCacheClose(t *testing.T) {
+	c, _ := newIntrospectionCache(t)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing twice, as deferred shutdown code may, must not panic.
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Limit allows Requests per Window. Tokens refill continuously, so a client
+// may burst up to Requests and then gets one request every
+// Window/Requests.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / float64(l.Window.Milliseconds())
+}
+
+// RateResult describes the bucket after a request was counted.
+type RateResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // until the next token, when not allowed
+	Reset      time.Duration // until the bucket is full again
+}
+
+// tokenBucket refills a bucket of capacity Requests by the time elapsed
+// since it was last touched and takes one token. It is the Lua twin of
+// RateLimiter.takeLocal, so both backends count the same way.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local rate = capacity / window
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, tostring(tokens)}
+`)
+
+// redisRetryInterval is how long the limiter stays on memory after Redis
+// failed, so requests do not each wait for a dial timeout.
+const redisRetryInterval = 5 * time.Second
+
+// RateLimiter keeps token buckets in Redis so every gateway replica shares
+// them. While Redis is unreachable it counts in memory instead, which
+// keeps each replica protected on its own.
+type RateLimiter struct {
+	log      *slog.Logger
+	client   *redis.Client
+	degraded atomic.Bool
+	retryAt  atomic.Int64 // unix nanos before which Redis is skipped
+
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+type memoryBucket struct {
+	tokens    float64
+	touched   time.Time
+	expiresAt time.Time
+}
+
+func NewRateLimiter(log *slog.Logger, client *redis.Client) *RateLimiter {
+	l := &RateLimiter{
+		log:     log,
+		client:  client,
+		buckets: make(map[string]*memoryBucket),
+		stop:    make(chan struct{}),
+	}
+	go l.evictLoop()
+	return l
+}
+
+// Close stops evicting expired in-memory buckets.
+func (l *RateLimiter) Close() error {
+	l.closeOnce.Do(func() { close(l.stop) })
+	return nil
+}
+
+// Allow counts one request against the bucket named key.
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit Limit) RateResult {
+	now := time.Now()
+	if now.UnixNano() < l.retryAt.Load() {
+		return l.takeLocal(key, limit, now)
+	}
+
+	res, err := tokenBucket.Run(ctx, l.client, []string{"ratelimit:" + key},
+		limit.Requests, limit.Window.Milliseconds(), now.UnixMilli()).Result()
+	if err == nil {
+		var allowed bool
+		var tokens float64
+		if allowed, tokens, err = parseBucket(res); err == nil {
+			if l.degraded.CompareAndSwap(true, false) {
+				l.log.Info("rate limiter is back on redis")
+			}
+			return result(limit, allowed, tokens)
+		}
+	}
+
+	if l.degraded.CompareAndSwap(false, true) {
+		l.log.Warn("rate limiter falls back to memory", "error", err)
+	}
+	l.retryAt.Store(now.Add(redisRetryInterval).UnixNano())
+	return l.takeLocal(key, limit, now)
+}
+
+func (l *RateLimiter) takeLocal(key string, limit Limit, now time.Time) RateResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Requests), touched: now}
+		l.buckets[key] = b
+	}
+	elapsed := float64(now.Sub(b.touched).Milliseconds())
+	b.tokens = math.Min(float64(limit.Requests), b.tokens+math.Max(0, elapsed)*limit.rate())
+	b.touched = now
+	b.expiresAt = now.Add(limit.Window)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(limit, allowed, b.tokens)
+}
+
+func (l *RateLimiter) evictLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		l.mu.Lock()
+		for k, b := range l.buckets {
+			if now.After(b.expiresAt) {
+				delete(l.buckets, k)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+func parseBucket(res interface{}) (bool, float64, error) {
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket reply %v", res)
+	}
+	allowed, _ := vals[0].(int64)
+	s, _ := vals[1].(string)
+	tokens, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected token bucket reply %v", res)
+	}
+	return allowed == 1, tokens, nil
+}
+
+func result(limit Limit, allowed bool, tokens float64) RateResult {
+	perToken := 1 / limit.rate()
+	res := RateResult{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Requests)-tokens)*perToken) * time.Millisecond,
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1-tokens)*perToken) * time.Millisecond
+	}
+	return res
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newRateLimiter returns a limiter on miniredis, or on an address nothing
+// listens on when down is set, so it counts in memory.
+func newRateLimiter(t *testing.T, down bool) *RateLimiter {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	if down {
+		mr.Close()
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	l := NewRateLimiter(discard, client)
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limit := Limit{Requests: 3, Window: 3 * time.Second}
+
+	for _, backend := range []struct {
+		name string
+		down bool
+	}{{"redis", false}, {"memory", true}} {
+		t.Run(backend.name, func(t *testing.T) {
+			l := newRateLimiter(t, backend.down)
+			ctx := context.Background()
+
+			steps := []struct {
+				key           string
+				wantAllowed   bool
+				wantRemaining int
+			}{
+				{"a", true, 2},
+				{"a", true, 1},
+				{"a", true, 0},
+				{"a", false, 0},
+				{"b", true, 2},
+			}
+			for i, step := range steps {
+				res := l.Allow(ctx, step.key, limit)
+				if res.Allowed != step.wantAllowed || res.Remaining != step.wantRemaining || res.Limit != 3 {
+					t.Fatalf("request %d to %s = %+v, want allowed %v with %d remaining",
+						i, step.key, res, step.wantAllowed, step.wantRemaining)
+				}
+				if !res.Allowed && (res.RetryAfter <= 0 || res.RetryAfter > time.Second) {
+					t.Errorf("request %d: RetryAfter = %v, want (0, 1s]", i, res.RetryAfter)
+				}
+			}
+
+			if got := l.degraded.Load(); got != backend.down {
+				t.Errorf("degraded = %v, want %v", got, backend.down)
+			}
+		})
+	}
+}
+
+// TestTokenBucketRefill checks that the Lua script and the in-memory
+// bucket refill alike, by driving both with the same clock.
+func TestTokenBucketRefill(t *testing.T) {
+	limit := Limit{Requests: 2, Window: 2 * time.Second}
+	start := time.UnixMilli(1_700_000_000_000)
+
+	steps := []struct {
+		after       time.Duration
+		wantAllowed bool
+	}{
+		{0, true},
+		{0, true},
+		{0, false},
+		{500 * time.Millisecond, false},
+		{time.Second, true},
+		{time.Second, false},
+		{time.Hour, true},
+		{time.Hour, true},
+		{time.Hour, false},
+	}
+
+	l := newRateLimiter(t, false)
+	ctx := context.Background()
+	for i, step := range steps {
+		now := start.Add(step.after)
+
+		local := l.takeLocal("k", limit, now)
+		reply, err := tokenBucket.Run(ctx, l.client, []string{"ratelimit:k"},
+			limit.Requests, limit.Window.Milliseconds(), now.UnixMilli()).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		allowed, _, err := parseBucket(reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if local.Allowed != step.wantAllowed || allowed != step.wantAllowed {
+			t.Errorf("step %d (+%v): memory allowed %v, redis allowed %v, want %v",
+				i, step.after, local.Allowed, allowed, step.wantAllowed)
+		}
+	}
+}
+
+func TestRateLimiterFallsBackAndRecovers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	l := NewRateLimiter(discard, client)
+	t.Cleanup(func() { l.Close() })
+	ctx := context.Background()
+	limit := Limit{Requests: 10, Window: time.Minute}
+
+	mr.SetError("LOADING")
+	if res := l.Allow(ctx, "k", limit); !res.Allowed || !l.degraded.Load() {
+		t.Fatalf("Allow() with redis failing = %+v, degraded %v", res, l.degraded.Load())
+	}
+
+	// Redis is skipped until the retry interval has passed.
+	mr.SetError("")
+	l.Allow(ctx, "k", limit)
+	if !l.degraded.Load() {
+		t.Fatal("limiter went back to redis before the retry interval")
+	}
+
+	l.retryAt.Store(0)
+	l.Allow(ctx, "k", limit)
+	if l.degraded.Load() {
+		t.Error("limiter did not go back to redis")
+	}
+	if !mr.Exists("ratelimit:k") {
+		t.Error("bucket was not written to redis")
+	}
+}
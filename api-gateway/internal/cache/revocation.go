@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// accessTokenTTL is the lifetime of access tokens issued by auth. A revoked
+// session only has to be remembered until its last access token expires.
+const accessTokenTTL = 15 * time.Minute
+
+// RevocationList keeps revoked access token ids and session ids in Redis
+// until their tokens would have expired anyway, plus the ids of users an
+// admin disabled.
+type RevocationList struct {
+	client *redis.Client
+}
+
+func NewRevocationList(client *redis.Client) *RevocationList {
+	return &RevocationList{client: client}
+}
+
+func (l *RevocationList) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return l.client.Set(ctx, revokedKey(jti), "1", ttl).Err()
+}
+
+func (l *RevocationList) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	err := l.client.Get(ctx, revokedKey(jti)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *RevocationList) RevokeSession(ctx context.Context, sid string) error {
+	if sid == "" {
+		return nil
+	}
+	return l.client.Set(ctx, sessionKey(sid), "1", accessTokenTTL).Err()
+}
+
+func (l *RevocationList) IsSessionRevoked(ctx context.Context, sid string) (bool, error) {
+	err := l.client.Get(ctx, sessionKey(sid)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DisableUser flags every token of the user as unusable until EnableUser.
+func (l *RevocationList) DisableUser(ctx context.Context, uid string) error {
+	return l.client.Set(ctx, disabledKey(uid), "1", 0).Err()
+}
+
+func (l *RevocationList) EnableUser(ctx context.Context, uid string) error {
+	return l.client.Del(ctx, disabledKey(uid)).Err()
+}
+
+func (l *RevocationList) IsUserDisabled(ctx context.Context, uid string) (bool, error) {
+	err := l.client.Get(ctx, disabledKey(uid)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func sessionKey(sid string) string {
+	return fmt.Sprintf("revoked:sid:%s", sid)
+}
+
+func disabledKey(uid string) string {
+	return fmt.Sprintf("disabled:user:%s", uid)
+}
+
+func revokedKey(jti string) string {
+	return fmt.Sprintf("revoked:jti:%s", jti)
+}
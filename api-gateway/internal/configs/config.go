@@ -1,10 +1,71 @@
 package configs
 
-import "github.com/ilyakaznacheev/cleanenv"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
 
+	"github.com/barcek2281/comics-store/pkg/clientip"
+	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Config is read from a YAML file; every field can be overridden by the
+// environment variable named in its env tag.
 type Config struct {
-	Port        int    `yaml:"port"`
-	StoragePath string `yaml:"storage_path"`
+	Port            int           `yaml:"port" env:"PORT" env-default:"8080"`
+	LogLevel        string        `yaml:"log_level" env:"LOG_LEVEL" env-default:"info"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
+	RequestTimeout  time.Duration `yaml:"request_timeout" env:"REQUEST_TIMEOUT" env-default:"10s"`
+	TrustedProxies  []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	Upstreams       Upstreams     `yaml:"upstreams"`
+	Redis           Redis         `yaml:"redis"`
+	RateLimit       RateLimit     `yaml:"rate_limit"`
+	Idempotency     Idempotency   `yaml:"idempotency"`
+	Introspection   Introspection `yaml:"introspection"`
+}
+
+// Upstreams are the gRPC addresses of the backend services.
+type Upstreams struct {
+	Auth      string `yaml:"auth" env:"AUTH_ADDR" env-default:"auth:50051"`
+	Inventory string `yaml:"inventory" env:"INVENTORY_ADDR" env-default:"inventory:50052"`
+	Order     string `yaml:"order" env:"ORDER_ADDR" env-default:"order:50053"`
+}
+
+type Redis struct {
+	Addr     string `yaml:"addr" env:"REDIS_ADDR" env-default:"redis:6379"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD"`
+	DB       int    `yaml:"db" env:"REDIS_DB" env-default:"0"`
+}
+
+// RateLimit sets how many requests a client may make per route. Routes
+// are keyed by their mux pattern, e.g. "GET /inventory/list".
+type RateLimit struct {
+	Enabled bool             `yaml:"enabled" env:"RATE_LIMIT_ENABLED" env-default:"true"`
+	Default Limit            `yaml:"default"`
+	Routes  map[string]Limit `yaml:"routes"`
+}
+
+type Limit struct {
+	Requests int           `yaml:"requests" env-default:"120"`
+	Window   time.Duration `yaml:"window" env-default:"1m"`
+}
+
+// Idempotency sets how long responses to requests with an
+// Idempotency-Key header are kept for replay.
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h"`
+}
+
+// Introspection decides what happens to a validly signed access token when
+// the auth service cannot be asked whether it is still active. By default
+// the request is refused; FailOpen serves it on the signature alone.
+type Introspection struct {
+	FailOpen bool `yaml:"fail_open" env:"INTROSPECTION_FAIL_OPEN" env-default:"false"`
 }
 
 func MustLoad(configPath string) *Config {
@@ -13,6 +74,87 @@ func MustLoad(configPath string) *Config {
 	if err := cleanenv.ReadConfig(configPath, &config); err != nil {
 		panic(err)
 	}
+	if err := config.Validate(); err != nil {
+		panic(fmt.Errorf("invalid config %s: %w", configPath, err))
+	}
 
 	return &config
 }
+
+// Validate reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	if _, err := c.Level(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("request_timeout must be positive"))
+	}
+	if _, err := c.ClientIPs(); err != nil {
+		errs = append(errs, fmt.Errorf("trusted_proxies: %w", err))
+	}
+	if c.Upstreams.Auth == "" || c.Upstreams.Inventory == "" || c.Upstreams.Order == "" {
+		errs = append(errs, errors.New("upstreams.auth, upstreams.inventory and upstreams.order are required"))
+	}
+	if c.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required"))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, errors.New("idempotency.ttl must be positive"))
+	}
+	if err := c.RateLimit.Default.validate("rate_limit.default"); err != nil {
+		errs = append(errs, err)
+	}
+	for route, limit := range c.RateLimit.Routes {
+		if err := limit.validate(fmt.Sprintf("rate_limit.routes[%q]", route)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l Limit) validate(name string) error {
+	if l.Requests <= 0 || l.Window < time.Millisecond {
+		return fmt.Errorf("%s needs positive requests and a window of at least 1ms", name)
+	}
+	return nil
+}
+
+// ClientIPs builds the resolver that trusts X-Forwarded-For only from
+// TrustedProxies.
+func (c *Config) ClientIPs() (*clientip.Resolver, error) {
+	return clientip.New(c.TrustedProxies)
+}
+
+// Level parses LogLevel.
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("log_level: %w", err)
+	}
+	return level, nil
+}
+
+// Print writes the config as YAML with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	c.Redis.Password = redact(c.Redis.Password)
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+)
+
+// ListUsers serves GET /admin/users?q=&page=&page_size=.
+func (h *AuthHandler) ListUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		page, _ := strconv.Atoi(query.Get("page"))
+		pageSize, _ := strconv.Atoi(query.Get("page_size"))
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.ListUsers(ctx, &authv1.ListUsersRequest{
+			Query:    query.Get("q"),
+			Page:     int32(page),
+			PageSize: int32(pageSize),
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) GetUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := userIDParam(r)
+		if err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.GetUser(ctx, &authv1.GetUserRequest{UserId: id})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) SetRoles() http.HandlerFunc {
+	type Req struct {
+		Roles []string `json:"roles"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := userIDParam(r)
+		if err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.SetRoles(ctx, &authv1.SetRolesRequest{UserId: id, Roles: req.Roles})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+// DisableUser blocks the account in auth and flags it in Redis so that
+// tokens it already holds stop working at the gateway straight away.
+func (h *AuthHandler) DisableUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := userIDParam(r)
+		if err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.DisableUser(ctx, &authv1.DisableUserRequest{UserId: id})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		h.Introspection.ForgetUser(id)
+		if err := h.revoked.DisableUser(ctx, strconv.FormatInt(id, 10)); err != nil {
+			h.log.WarnContext(r.Context(), "cannot flag disabled user", "user_id", id, "error", err)
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) EnableUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := userIDParam(r)
+		if err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.EnableUser(ctx, &authv1.EnableUserRequest{UserId: id})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		if err := h.revoked.EnableUser(ctx, strconv.FormatInt(id, 10)); err != nil {
+			h.log.WarnContext(r.Context(), "cannot clear disabled user flag", "user_id", id, "error", err)
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func userIDParam(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid user id")
+	}
+	return id, nil
+}
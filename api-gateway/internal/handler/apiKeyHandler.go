@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+)
+
+// CreateAPIKey returns the full key exactly once; only its prefix can be
+// seen afterwards.
+func (h *AuthHandler) CreateAPIKey() http.HandlerFunc {
+	type Req struct {
+		ServiceAccount string   `json:"service_account"`
+		Scopes         []string `json:"scopes"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.CreateAPIKey(ctx, &authv1.CreateAPIKeyRequest{
+			ServiceAccount: req.ServiceAccount,
+			Scopes:         req.Scopes,
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusCreated, res)
+	}
+}
+
+// ListAPIKeys serves GET /admin/api-keys?service_account=.
+func (h *AuthHandler) ListAPIKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.ListAPIKeys(ctx, &authv1.ListAPIKeysRequest{
+			ServiceAccount: r.URL.Query().Get("service_account"),
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) RevokeAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			utils.Error(w, r, http.StatusBadRequest, fmt.Errorf("invalid api key id"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.RevokeAPIKey(ctx, &authv1.RevokeAPIKeyRequest{Id: id})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+)
+
+// ListAuditEvents serves GET /admin/audit-events?user_id=&type=&since=&until=&page=&page_size=.
+// since and until are RFC 3339 timestamps.
+func (h *AuthHandler) ListAuditEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var userID int64
+		if v := query.Get("user_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				utils.Error(w, r, http.StatusBadRequest, fmt.Errorf("invalid user_id"))
+				return
+			}
+			userID = id
+		}
+		page, _ := strconv.Atoi(query.Get("page"))
+		pageSize, _ := strconv.Atoi(query.Get("page_size"))
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.ListAuditEvents(ctx, &authv1.ListAuditEventsRequest{
+			UserId:   userID,
+			Type:     query.Get("type"),
+			Since:    query.Get("since"),
+			Until:    query.Get("until"),
+			Page:     int32(page),
+			PageSize: int32(pageSize),
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
@@ -3,13 +3,17 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/barcek2281/comics-store/api-gateway/internal/cache"
+	"github.com/barcek2281/comics-store/api-gateway/internal/middleware"
 	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	"github.com/barcek2281/comics-store/pkg/requestid"
+	"github.com/barcek2281/comics-store/pkg/validate"
 	authv1 "github.com/barcek2281/proto/gen/go/auth"
 	"github.com/go-redis/redis/v8"
 	"google.golang.org/grpc"
@@ -18,11 +22,18 @@ import (
 type AuthHandler struct {
 	log         *slog.Logger
 	AuthClient  authv1.AuthClient
+	conn        *grpc.ClientConn
 	redisClient *redis.Client
+	revoked     *cache.RevocationList
+	timeout     time.Duration
+
+	// Introspection caches what the auth service reports about tokens;
+	// handlers that revoke tokens evict them from it.
+	Introspection *cache.IntrospectionCache
 }
 
-func NewAuthHandler(log *slog.Logger, portAuth int) *AuthHandler {
-	conn, err := grpc.Dial(fmt.Sprintf("auth:%d", portAuth), grpc.WithInsecure())
+func NewAuthHandler(log *slog.Logger, addr string, timeout time.Duration, redisClient *redis.Client, revoked *cache.RevocationList) *AuthHandler {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor))
 	if err != nil {
 		return nil
 	}
@@ -30,75 +41,412 @@ func NewAuthHandler(log *slog.Logger, portAuth int) *AuthHandler {
 	return &AuthHandler{
 		log:         log,
 		AuthClient:  AuthClient,
-		redisClient: cache.NewRedisClient(),
+		conn:        conn,
+		redisClient: redisClient,
+		revoked:     revoked,
+		timeout:     timeout,
+
+		Introspection: cache.NewIntrospectionCache(AuthClient),
 	}
 }
 
+// Close closes the connection to the auth service.
+func (h *AuthHandler) Close() error {
+	return errors.Join(h.Introspection.Close(), h.conn.Close())
+}
+
 func (h *AuthHandler) Register() http.HandlerFunc {
 	type Req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"required,email,max=254"`
+		Password string `json:"password" validate:"required"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
 		var req Req
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			utils.Error(w, r, http.StatusBadRequest, err)
 			return
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		usedEmail := fmt.Sprintf("email:%s", req.Email)
-		_, err := h.redisClient.Get(ctx, usedEmail).Result()
-		if err == nil {
-			utils.Response(w, r, http.StatusBadRequest, "no")
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
 			return
 		}
-		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withClient(ctx, r)
 
 		res, err := h.AuthClient.Register(ctx, &authv1.RegisterRequest{
 			Email:    req.Email,
 			Password: req.Password,
 		})
 		if err != nil {
-			ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
-			defer cancel()
-
-			h.redisClient.Set(ctx, usedEmail, []byte("0"), time.Minute*5)
-			slog.Info("set data for redis")
-			utils.Error(w, r, http.StatusInternalServerError, err)
+			utils.GRPCError(w, r, err)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"token": res.Token})
+
+		utils.Response(w, r, http.StatusOK, map[string]string{"token": res.Token, "refresh_token": res.RefreshToken})
 	}
 }
 
 func (h *AuthHandler) Login() http.HandlerFunc {
 	type Req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"required,email,max=254"`
+		Password string `json:"password" validate:"required"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
 		var req Req
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+			utils.Error(w, r, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withClient(ctx, r)
 
 		res, err := h.AuthClient.Login(ctx, &authv1.LoginRequest{
 			Email:    req.Email,
 			Password: req.Password,
 		})
 		if err != nil {
-			http.Error(w, "authentication failed", http.StatusUnauthorized)
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		if res.TwoFactorRequired {
+			utils.Response(w, r, http.StatusOK, map[string]interface{}{
+				"two_factor_required": true,
+				"challenge_token":     res.ChallengeToken,
+			})
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, map[string]string{"token": res.Token, "refresh_token": res.RefreshToken})
+	}
+}
+
+func (h *AuthHandler) Refresh() http.HandlerFunc {
+	type Req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withClient(ctx, r)
+
+		res, err := h.AuthClient.Refresh(ctx, &authv1.RefreshRequest{RefreshToken: req.RefreshToken})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, map[string]string{"token": res.Token, "refresh_token": res.RefreshToken})
+	}
+}
+
+// Logout revokes the refresh token family in the auth service and puts the
+// presented access token on the revocation list until it expires.
+func (h *AuthHandler) Logout() http.HandlerFunc {
+	type Req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+
+		_, err := h.AuthClient.Logout(ctx, &authv1.LogoutRequest{
+			RefreshToken: req.RefreshToken,
+			Token:        middleware.Token(r.Context()),
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+		h.Introspection.Forget(middleware.Token(r.Context()))
+
+		jti, ttl := middleware.TokenID(r.Context())
+		if err := h.revoked.Revoke(ctx, jti, ttl); err != nil {
+			h.log.ErrorContext(r.Context(), "cannot revoke access token", "error", err)
+			utils.Error(w, r, http.StatusInternalServerError, fmt.Errorf("logout failed"))
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+func (h *AuthHandler) UnlockAccount() http.HandlerFunc {
+	type Req struct {
+		Email string `json:"email" validate:"required,email,max=254"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.UnlockAccount(ctx, &authv1.UnlockAccountRequest{Email: req.Email})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+// VerifyEmail consumes a verification token POSTed by the page
+// ConfirmPage serves for the mailed link, or sent as JSON.
+func (h *AuthHandler) VerifyEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := bodyToken(r)
+		if err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+
+		res, err := h.AuthClient.VerifyEmail(ctx, &authv1.VerifyEmailRequest{Token: token})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) ResendVerification() http.HandlerFunc {
+	type Req struct {
+		Email string `json:"email" validate:"required,email,max=254"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+
+		res, err := h.AuthClient.ResendVerification(ctx, &authv1.ResendVerificationRequest{Email: req.Email})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) RequestPasswordReset() http.HandlerFunc {
+	type Req struct {
+		Email string `json:"email" validate:"required,email,max=254"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+
+		res, err := h.AuthClient.RequestPasswordReset(ctx, &authv1.RequestPasswordResetRequest{Email: req.Email})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) ResetPassword() http.HandlerFunc {
+	type Req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if isForm(r) {
+			// Sent by the ResetPasswordPage form.
+			if err := r.ParseForm(); err != nil {
+				utils.Error(w, r, http.StatusBadRequest, err)
+				return
+			}
+			req.Token = r.PostForm.Get("token")
+			req.NewPassword = r.PostForm.Get("new_password")
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+
+		res, err := h.AuthClient.ResetPassword(ctx, &authv1.ResetPasswordRequest{
+			Token:       req.Token,
+			NewPassword: req.NewPassword,
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) ChangePassword() http.HandlerFunc {
+	type Req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.ChangePassword(ctx, &authv1.ChangePasswordRequest{
+			CurrentPassword: req.CurrentPassword,
+			NewPassword:     req.NewPassword,
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) EnrollTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.EnrollTOTP(ctx, &authv1.EnrollTOTPRequest{})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, map[string]interface{}{
+			"secret":      res.Secret,
+			"otpauth_uri": res.OtpauthUri,
+			"qr_png":      res.QrPng,
+		})
+	}
+}
+
+func (h *AuthHandler) ConfirmTOTP() http.HandlerFunc {
+	type Req struct {
+		Code string `json:"code"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.ConfirmTOTP(ctx, &authv1.ConfirmTOTPRequest{Code: req.Code})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, map[string][]string{"recovery_codes": res.RecoveryCodes})
+	}
+}
+
+func (h *AuthHandler) VerifySecondFactor() http.HandlerFunc {
+	type Req struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withClient(ctx, r)
+
+		res, err := h.AuthClient.VerifySecondFactor(ctx, &authv1.VerifySecondFactorRequest{
+			ChallengeToken: req.ChallengeToken,
+			Code:           req.Code,
+			RecoveryCode:   req.RecoveryCode,
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"token": res.Token})
+		utils.Response(w, r, http.StatusOK, map[string]string{"token": res.Token, "refresh_token": res.RefreshToken})
 	}
 }
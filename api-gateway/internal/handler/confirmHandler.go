@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"mime"
+	"net/http"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+)
+
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+{{- if .Password}}
+<label>New password <input type="password" name="new_password" autocomplete="new-password" required></label>
+{{- end}}
+<button type="submit">{{.Button}}</button>
+</form>
+</body>
+</html>
+`))
+
+// ConfirmPage serves the page a mailed link opens. It only shows a form
+// that POSTs the link's token back to the same path, so mail scanners and
+// link previews that fetch the URL do not use the token up.
+func ConfirmPage(title, button string) http.HandlerFunc {
+	return tokenPage(title, button, false)
+}
+
+// ResetPasswordPage serves the page a mailed password reset link opens. It
+// asks for the new password and POSTs it together with the link's token.
+func ResetPasswordPage() http.HandlerFunc {
+	return tokenPage("Choose a new password", "Reset password", true)
+}
+
+func tokenPage(title, button string, password bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			utils.Error(w, r, http.StatusBadRequest, errors.New("missing token"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		noStore(w)
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		confirmPage.Execute(w, struct {
+			Title, Button, Action, Token string
+			Password                     bool
+		}{title, button, r.URL.Path, token, password})
+	}
+}
+
+// noStore marks a response that carries a credential, so that neither
+// HTTP caches nor the idempotency middleware keep a copy of it.
+func noStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// bodyToken reads the token of a POSTed confirmation, sent either by the
+// ConfirmPage form or as JSON.
+func bodyToken(r *http.Request) (string, error) {
+	if isForm(r) {
+		if err := r.ParseForm(); err != nil {
+			return "", err
+		}
+		return r.PostForm.Get("token"), nil
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return "", err
+	}
+	return req.Token, nil
+}
+
+// isForm reports whether r carries a POSTed HTML form.
+func isForm(r *http.Request) bool {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return ct == "application/x-www-form-urlencoded"
+}
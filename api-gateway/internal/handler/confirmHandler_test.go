@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc"
+)
+
+func TestConfirmPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody []string
+	}{
+		{
+			name:     "renders a post form",
+			url:      "/auth/verify-email?token=abc",
+			wantCode: http.StatusOK,
+			wantBody: []string{`method="post"`, `action="/auth/verify-email"`, `name="token" value="abc"`},
+		},
+		{
+			name:     "escapes the token",
+			url:      "/auth/verify-email?token=%22%3E%3Cscript%3E",
+			wantCode: http.StatusOK,
+			wantBody: []string{`value="&#34;&gt;&lt;script&gt;"`},
+		},
+		{
+			name:     "missing token",
+			url:      "/auth/verify-email",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ConfirmPage("Verify your email", "Verify")(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body does not contain %q:\n%s", want, rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestBodyToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantErr     bool
+	}{
+		{"form", "application/x-www-form-urlencoded", "token=abc", "abc", false},
+		{"form with charset", "application/x-www-form-urlencoded; charset=utf-8", "token=abc", "abc", false},
+		{"json", "application/json", `{"token":"abc"}`, "abc", false},
+		{"invalid json", "application/json", `{`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/auth/verify-email?token=fromquery", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			got, err := bodyToken(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("token = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// resetAuth records the password reset the gateway forwards.
+type resetAuth struct {
+	authv1.AuthClient
+	got *authv1.ResetPasswordRequest
+}
+
+func (a *resetAuth) ResetPassword(ctx context.Context, in *authv1.ResetPasswordRequest, opts ...grpc.CallOption) (*authv1.ResetPasswordResponse, error) {
+	a.got = in
+	return &authv1.ResetPasswordResponse{}, nil
+}
+
+var formField = regexp.MustCompile(`<input type="hidden" name="([^"]+)" value="([^"]*)">`)
+
+func TestResetPasswordLink(t *testing.T) {
+	auth := &resetAuth{}
+	h := &AuthHandler{log: slog.New(slog.NewTextHandler(io.Discard, nil)), AuthClient: auth, timeout: time.Second}
+	mux := http.NewServeMux()
+	mux.Handle("GET /auth/password/reset", ResetPasswordPage())
+	mux.Handle("POST /auth/password/reset", h.ResetPassword())
+
+	// Open the link the auth service mails.
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/password/reset?token=abc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	page := rec.Body.String()
+	for _, want := range []string{`method="post"`, `action="/auth/password/reset"`, `type="password" name="new_password"`} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("page does not contain %q:\n%s", want, page)
+		}
+	}
+
+	// Submit the form the way a browser would.
+	form := url.Values{"new_password": {"correct horse battery staple"}}
+	for _, m := range formField.FindAllStringSubmatch(page, -1) {
+		form.Set(m[1], html.UnescapeString(m[2]))
+	}
+	r := httptest.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if auth.got == nil || auth.got.Token != "abc" || auth.got.NewPassword != "correct horse battery staple" {
+		t.Errorf("ResetPassword got %v, want token abc with the new password", auth.got)
+	}
+}
@@ -3,14 +3,15 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/barcek2281/comics-store/api-gateway/internal/cache"
 	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	"github.com/barcek2281/comics-store/pkg/requestid"
+	"github.com/barcek2281/comics-store/pkg/validate"
 	inventoryv1 "github.com/barcek2281/proto/gen/go/inventory"
 	"github.com/go-redis/redis/v8"
 	"google.golang.org/grpc"
@@ -21,11 +22,13 @@ const InventoryCachedKey = "inventoryList"
 type InventoryHandler struct {
 	log             *slog.Logger
 	InventoryClient inventoryv1.InventoryClient
+	conn            *grpc.ClientConn
 	redisClient     *redis.Client
+	timeout         time.Duration
 }
 
-func NewInventoryHandler(log *slog.Logger, portInventory int) *InventoryHandler {
-	conn, err := grpc.NewClient(fmt.Sprintf("inventory:%d", portInventory), grpc.WithInsecure())
+func NewInventoryHandler(log *slog.Logger, addr string, timeout time.Duration, redisClient *redis.Client) *InventoryHandler {
+	conn, err := grpc.NewClient(addr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor))
 	if err != nil {
 		log.Error("failed to connect to inventory service", slog.String("error", err.Error()))
 		return nil
@@ -34,29 +37,41 @@ func NewInventoryHandler(log *slog.Logger, portInventory int) *InventoryHandler
 	return &InventoryHandler{
 		log:             log,
 		InventoryClient: client,
-		redisClient:     cache.NewRedisClient(),
+		conn:            conn,
+		redisClient:     redisClient,
+		timeout:         timeout,
 	}
 }
 
+// Close closes the connection to the inventory service.
+func (h *InventoryHandler) Close() error {
+	return h.conn.Close()
+}
+
 func (h *InventoryHandler) Create() http.HandlerFunc {
 	type Req struct {
-		Title       string `json:"title"`
-		Author      string `json:"author"`
-		Description string `json:"description"`
-		ReleaseDate string `json:"release_date"`
-		Price       int64  `json:"price"`
-		Quantity    int32  `json:"quantity"`
+		Title       string `json:"title" validate:"required,max=200"`
+		Author      string `json:"author" validate:"required,max=100"`
+		Description string `json:"description" validate:"max=2000"`
+		ReleaseDate string `json:"release_date" validate:"date"`
+		Price       int64  `json:"price" validate:"min=0"`
+		Quantity    int32  `json:"quantity" validate:"min=0"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req Req
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+			utils.Error(w, r, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 
 		res, err := h.InventoryClient.Create(ctx, &inventoryv1.CreateRequest{
 			Title:       req.Title,
@@ -67,13 +82,13 @@ func (h *InventoryHandler) Create() http.HandlerFunc {
 			Quantity:    int64(req.Quantity),
 		})
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to create comic: %v", err), http.StatusInternalServerError)
+			utils.GRPCError(w, r, err)
 			return
 		}
-		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 		defer cancel()
 		h.redisClient.Del(ctx, InventoryCachedKey)
-		slog.Info("updated redis cached data")
+		slog.InfoContext(ctx, "updated redis cached data")
 		utils.Response(w, r, http.StatusOK, map[string]int64{"id": res.Id})
 	}
 }
@@ -82,18 +97,19 @@ func (h *InventoryHandler) Get() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.URL.Query().Get("id")
 		if id == "" {
-			http.Error(w, "missing id parameter", http.StatusBadRequest)
+			utils.Error(w, r, http.StatusBadRequest, errors.New("missing id parameter"))
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 
 		numId, _ := strconv.Atoi(id)
 
 		res, err := h.InventoryClient.Get(ctx, &inventoryv1.GetRequest{Id: int64(numId)})
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to get comic: %v", err), http.StatusInternalServerError)
+			utils.GRPCError(w, r, err)
 			return
 		}
 
@@ -103,28 +119,29 @@ func (h *InventoryHandler) Get() http.HandlerFunc {
 
 func (h *InventoryHandler) List() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 		page := r.URL.Query().Get("page")
 		price := r.URL.Query().Get("price-up")
 		cachedKey := InventoryCachedKey
 
 		if price == "" && page == "" {
-			ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+			ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 			defer cancel()
 			cached, err := h.redisClient.Get(ctx, cachedKey).Result()
 			if err == nil {
 				var prod []*inventoryv1.Comics
 				_ = json.Unmarshal([]byte(cached), &prod)
 				utils.Response(w, r, http.StatusOK, prod)
-				slog.Info("get cached data from redis")
+				slog.InfoContext(ctx, "get cached data from redis")
 				return
 			}
 		}
 
 		res, err := h.InventoryClient.List(ctx, &inventoryv1.ListRequest{})
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to list comics: %v", err), http.StatusInternalServerError)
+			utils.GRPCError(w, r, err)
 			return
 		}
 
@@ -155,11 +172,11 @@ func (h *InventoryHandler) List() http.HandlerFunc {
 		}
 
 		if price == "" && page == "" {
-			ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+			ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 			defer cancel()
 			b, _ := json.Marshal(res.Comics)
 			h.redisClient.Set(ctx, cachedKey, b, time.Minute*5)
-			slog.Info("set data for redis")
+			slog.InfoContext(ctx, "set data for redis")
 
 		}
 		utils.Response(w, r, http.StatusOK, res.Comics)
@@ -170,23 +187,24 @@ func (h *InventoryHandler) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.URL.Query().Get("id")
 		if id == "" {
-			http.Error(w, "missing id parameter", http.StatusBadRequest)
+			utils.Error(w, r, http.StatusBadRequest, errors.New("missing id parameter"))
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 		numId, _ := strconv.Atoi(id)
 
 		res, err := h.InventoryClient.Delete(ctx, &inventoryv1.DeleteRequest{Id: int64(numId)})
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to delete comic: %v", err), http.StatusInternalServerError)
+			utils.GRPCError(w, r, err)
 			return
 		}
-		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 		defer cancel()
 		h.redisClient.Del(ctx, InventoryCachedKey)
-		slog.Info("updated redis cached data")
+		slog.InfoContext(ctx, "updated redis cached data")
 
 		utils.Response(w, r, http.StatusOK, res)
 	}
@@ -194,24 +212,29 @@ func (h *InventoryHandler) Delete() http.HandlerFunc {
 
 func (h *InventoryHandler) Update() http.HandlerFunc {
 	type Req struct {
-		Id          int64  `json:"id"`
-		Title       string `json:"title"`
-		Author      string `json:"author"`
-		Description string `json:"description"`
-		ReleaseDate string `json:"release_date"`
-		Price       int64  `json:"price"`
-		Quantity    int32  `json:"quantity"`
+		Id          int64  `json:"id" validate:"required"`
+		Title       string `json:"title" validate:"required,max=200"`
+		Author      string `json:"author" validate:"required,max=100"`
+		Description string `json:"description" validate:"max=2000"`
+		ReleaseDate string `json:"release_date" validate:"date"`
+		Price       int64  `json:"price" validate:"min=0"`
+		Quantity    int32  `json:"quantity" validate:"min=0"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req Req
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+			utils.Error(w, r, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 
 		res, err := h.InventoryClient.Update(ctx, &inventoryv1.UpdateRequest{
 			Id:          req.Id,
@@ -223,13 +246,13 @@ func (h *InventoryHandler) Update() http.HandlerFunc {
 			Quantity:    int64(req.Quantity),
 		})
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to update comic: %v", err), http.StatusInternalServerError)
+			utils.GRPCError(w, r, err)
 			return
 		}
-		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 		defer cancel()
 		h.redisClient.Del(ctx, InventoryCachedKey)
-		slog.Info("updated redis cached data")
+		slog.InfoContext(ctx, "updated redis cached data")
 		utils.Response(w, r, http.StatusOK, res)
 	}
 }
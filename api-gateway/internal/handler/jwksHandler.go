@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/jwks"
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+)
+
+// JWKS serves the auth service's public signing keys in RFC 7517 form.
+func JWKS(keys *jwks.Cache) http.HandlerFunc {
+	type Key struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(requestContext(r), 10*time.Second)
+		defer cancel()
+
+		res, err := keys.Keys(ctx)
+		if err != nil {
+			utils.Error(w, r, http.StatusServiceUnavailable, fmt.Errorf("jwks unavailable"))
+			return
+		}
+
+		set := make([]Key, 0, len(res))
+		for _, k := range res {
+			set = append(set, Key{Kid: k.Kid, Kty: k.Kty, Crv: k.Crv, X: k.X, Alg: k.Alg, Use: k.Use})
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		utils.Response(w, r, http.StatusOK, map[string][]Key{"keys": set})
+	}
+}
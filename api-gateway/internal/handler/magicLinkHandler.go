@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	"github.com/barcek2281/comics-store/pkg/validate"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+)
+
+func (h *AuthHandler) RequestMagicLink() http.HandlerFunc {
+	type Req struct {
+		Email string `json:"email" validate:"required,email,max=254"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+
+		res, err := h.AuthClient.RequestMagicLink(ctx, &authv1.RequestMagicLinkRequest{Email: req.Email})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+// ConsumeMagicLink accepts the token POSTed by the page ConfirmPage serves
+// for the emailed link, or sent as JSON, and answers like Login.
+func (h *AuthHandler) ConsumeMagicLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
+		token, err := bodyToken(r)
+		if err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withClient(ctx, r)
+
+		res, err := h.AuthClient.ConsumeMagicLink(ctx, &authv1.ConsumeMagicLinkRequest{Token: token})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		if res.TwoFactorRequired {
+			utils.Response(w, r, http.StatusOK, map[string]interface{}{
+				"two_factor_required": true,
+				"challenge_token":     res.ChallengeToken,
+			})
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, map[string]string{"token": res.Token, "refresh_token": res.RefreshToken})
+	}
+}
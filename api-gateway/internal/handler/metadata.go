@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/middleware"
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	"github.com/barcek2281/comics-store/pkg/clientip"
+	"google.golang.org/grpc/metadata"
+)
+
+// withAuth forwards the caller's access token or API key to upstream gRPC
+// services so they can introspect it themselves.
+func withAuth(ctx context.Context, r *http.Request) context.Context {
+	if key := middleware.APIKey(r.Context()); key != "" {
+		return metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+	}
+
+	token := middleware.Token(r.Context())
+	if token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// withClient forwards the end client's address and user agent, which auth
+// records on the sessions it starts.
+func withClient(ctx context.Context, r *http.Request) context.Context {
+	return metadata.AppendToOutgoingContext(ctx,
+		clientip.MetadataKey, utils.ClientIP(r),
+		"x-user-agent", r.UserAgent(),
+	)
+}
+
+// requestContext carries the request's values, such as its id, into
+// upstream calls without tying them to the client connection, so a call
+// that has started is not abandoned halfway when the client goes away.
+func requestContext(r *http.Request) context.Context {
+	return context.WithoutCancel(r.Context())
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+)
+
+// oidcStateCookie ties a sign-in to the browser that started it, so that a
+// callback URL made for someone else's browser cannot log this one in. It
+// holds a hash of the state and lives as long as the state does in auth.
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// OIDCStart redirects the browser to the identity provider's sign-in page.
+func (h *AuthHandler) OIDCStart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+
+		res, err := h.AuthClient.StartOIDCLogin(ctx, &authv1.StartOIDCLoginRequest{Provider: r.PathValue("provider")})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		authURL, err := url.Parse(res.AuthorizationUrl)
+		if err != nil || authURL.Query().Get("state") == "" {
+			utils.Error(w, r, http.StatusBadGateway, errors.New("invalid authorization url"))
+			return
+		}
+		http.SetCookie(w, oidcCookie(r, hashState(authURL.Query().Get("state")), int(oidcStateTTL.Seconds())))
+
+		http.Redirect(w, r, res.AuthorizationUrl, http.StatusFound)
+	}
+}
+
+// OIDCCallback is the redirect URI registered with the provider. It
+// answers like Login.
+func (h *AuthHandler) OIDCCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		noStore(w)
+
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			utils.Error(w, r, http.StatusUnauthorized, fmt.Errorf("identity provider: %s", e))
+			return
+		}
+
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(hashState(q.Get("state")))) != 1 {
+			utils.Error(w, r, http.StatusBadRequest, errors.New("sign-in was not started in this browser"))
+			return
+		}
+		http.SetCookie(w, oidcCookie(r, "", -1))
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withClient(ctx, r)
+
+		res, err := h.AuthClient.FinishOIDCLogin(ctx, &authv1.FinishOIDCLoginRequest{
+			Provider: r.PathValue("provider"),
+			Code:     q.Get("code"),
+			State:    q.Get("state"),
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		if res.TwoFactorRequired {
+			utils.Response(w, r, http.StatusOK, map[string]interface{}{
+				"two_factor_required": true,
+				"challenge_token":     res.ChallengeToken,
+			})
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, map[string]string{"token": res.Token, "refresh_token": res.RefreshToken})
+	}
+}
+
+func oidcCookie(r *http.Request, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/auth/oidc/" + r.PathValue("provider"),
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc"
+)
+
+// oidcAuth starts every sign-in with state "s1" and counts finished ones.
+type oidcAuth struct {
+	authv1.AuthClient
+	finished int
+}
+
+func (a *oidcAuth) StartOIDCLogin(ctx context.Context, in *authv1.StartOIDCLoginRequest, opts ...grpc.CallOption) (*authv1.StartOIDCLoginResponse, error) {
+	return &authv1.StartOIDCLoginResponse{AuthorizationUrl: "https://idp.test/authorize?client_id=shop&state=s1"}, nil
+}
+
+func (a *oidcAuth) FinishOIDCLogin(ctx context.Context, in *authv1.FinishOIDCLoginRequest, opts ...grpc.CallOption) (*authv1.FinishOIDCLoginResponse, error) {
+	a.finished++
+	return &authv1.FinishOIDCLoginResponse{Token: "t", RefreshToken: "r"}, nil
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	auth := &oidcAuth{}
+	h := &AuthHandler{log: slog.New(slog.NewTextHandler(io.Discard, nil)), AuthClient: auth, timeout: time.Second}
+	mux := http.NewServeMux()
+	mux.Handle("GET /auth/oidc/{provider}/start", h.OIDCStart())
+	mux.Handle("GET /auth/oidc/{provider}/callback", h.OIDCCallback())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/google/start", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("start status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode || cookies[0].Value == "s1" {
+		t.Fatalf("start cookies = %v, want one HttpOnly Lax cookie with a hash of the state", cookies)
+	}
+	started := cookies[0]
+
+	tests := []struct {
+		name         string
+		cookie       *http.Cookie
+		state        string
+		wantCode     int
+		wantFinished int
+	}{
+		{"no cookie", nil, "s1", http.StatusBadRequest, 0},
+		{"other browser's state", started, "s2", http.StatusBadRequest, 0},
+		{"forged cookie", &http.Cookie{Name: oidcStateCookie, Value: "s1"}, "s1", http.StatusBadRequest, 0},
+		{"same browser", started, "s1", http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth.finished = 0
+			r := httptest.NewRequest(http.MethodGet, "/auth/oidc/google/callback?code=c&state="+tt.state, nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, r)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("callback status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if auth.finished != tt.wantFinished {
+				t.Errorf("FinishOIDCLogin called %d times, want %d", auth.finished, tt.wantFinished)
+			}
+		})
+	}
+}
@@ -8,21 +8,27 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/barcek2281/comics-store/api-gateway/internal/cache"
+	"github.com/barcek2281/comics-store/api-gateway/internal/middleware"
 	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	"github.com/barcek2281/comics-store/pkg/requestid"
+	"github.com/barcek2281/comics-store/pkg/validate"
 	orderv1 "github.com/barcek2281/proto/gen/go/order"
 	"github.com/go-redis/redis/v8"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type OrderHandler struct {
 	log         *slog.Logger
 	OrderClient orderv1.OrderClient
+	conn        *grpc.ClientConn
 	redisClient *redis.Client
+	timeout     time.Duration
 }
 
-func NewOrderHandler(log *slog.Logger, portOrder int) *OrderHandler {
-	conn, err := grpc.Dial(fmt.Sprintf("order:%d", portOrder), grpc.WithInsecure())
+func NewOrderHandler(log *slog.Logger, addr string, timeout time.Duration, redisClient *redis.Client) *OrderHandler {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor))
 	if err != nil {
 		log.Error("failed to connect to order service", slog.String("error", err.Error()))
 		return nil
@@ -32,27 +38,45 @@ func NewOrderHandler(log *slog.Logger, portOrder int) *OrderHandler {
 	return &OrderHandler{
 		log:         log,
 		OrderClient: client,
-		redisClient: cache.NewRedisClient(),
+		conn:        conn,
+		redisClient: redisClient,
+		timeout:     timeout,
 	}
 }
 
+// Close closes the connection to the order service.
+func (h *OrderHandler) Close() error {
+	return h.conn.Close()
+}
+
 func (h *OrderHandler) CreateOrder() http.HandlerFunc {
 	type Item struct {
-		ProductId string `json:"product_id"`
-		Quantity  int32  `json:"quantity"`
+		ProductId string `json:"product_id" validate:"required"`
+		Quantity  int32  `json:"quantity" validate:"min=1,max=1000"`
 	}
 	type Req struct {
-		UserId string `json:"user_id"`
-		Items  []Item `json:"items"`
+		Items           []Item `json:"items" validate:"required,max=100"`
+		ClientReference string `json:"client_reference" validate:"max=64"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req Req
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			utils.Error(w, r, http.StatusBadRequest, fmt.Errorf("invalid request body"))
-			h.log.Error("invalid body")
+			h.log.ErrorContext(r.Context(), "invalid body")
+			return
+		}
+		// Without an explicit reference the idempotency key doubles as one,
+		// so the order service refuses a duplicate even if the gateway's
+		// record of the key is lost.
+		if key := r.Header.Get(middleware.IdempotencyKeyHeader); req.ClientReference == "" && len(key) <= 64 {
+			req.ClientReference = key
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
 			return
 		}
+		userID := middleware.UserID(r.Context())
 
 		var items []*orderv1.OrderItem
 		for _, i := range req.Items {
@@ -62,22 +86,24 @@ func (h *OrderHandler) CreateOrder() http.HandlerFunc {
 			})
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 
 		res, err := h.OrderClient.CreateOrder(ctx, &orderv1.CreateOrderRequest{
-			UserId: req.UserId,
-			Items:  items,
+			UserId:          userID,
+			Items:           items,
+			ClientReference: req.ClientReference,
 		})
 		if err != nil {
-			utils.Error(w, r, http.StatusInternalServerError, fmt.Errorf("failed to create order: %v", err))
+			utils.GRPCError(w, r, err)
 			return
 		}
-		cachedKey := fmt.Sprintf("listOrders:%s", req.UserId)
-		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		cachedKey := fmt.Sprintf("listOrders:%s", userID)
+		ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 		defer cancel()
 		h.redisClient.Del(ctx, cachedKey)
-		slog.Info("updated redis cached data")
+		slog.InfoContext(ctx, "updated redis cached data")
 
 		utils.Response(w, r, http.StatusOK, res)
 
@@ -91,12 +117,13 @@ func (h *OrderHandler) GetOrder() http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 
-		res, err := h.OrderClient.GetOrder(ctx, &orderv1.GetOrderRequest{OrderId: orderID})
+		res, err := h.ownedOrder(ctx, r, orderID)
 		if err != nil {
-			utils.Error(w, r, http.StatusInternalServerError, fmt.Errorf("failed to get order: %v", err))
+			utils.GRPCError(w, r, err)
 			return
 		}
 
@@ -112,84 +139,84 @@ func (h *OrderHandler) UpdateOrder() http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		order, err := h.ownedOrder(ctx, r, orderID)
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
 
 		res, err := h.OrderClient.UpdateOrder(ctx, &orderv1.GetOrderRequest{OrderId: orderID})
 		if err != nil {
-			utils.Error(w, r, http.StatusInternalServerError, fmt.Errorf("failed to update order: %v", err))
+			utils.GRPCError(w, r, err)
 			return
 		}
 
-		cachedKey := fmt.Sprintf("listOrders:%s", orderID)
-		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		cachedKey := fmt.Sprintf("listOrders:%s", order.UserId)
+		ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 		defer cancel()
 		h.redisClient.Del(ctx, cachedKey)
-		slog.Info("updated redis cached data")
+		slog.InfoContext(ctx, "updated redis cached data")
 		utils.Response(w, r, http.StatusOK, res)
 
 	}
 }
 func (h *OrderHandler) CloseOrder() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID := r.URL.Query().Get("user_id")
-		if userID == "" {
-			utils.Error(w, r, http.StatusBadRequest, fmt.Errorf("missing user id"))
-			return
-		}
+		userID := middleware.UserID(r.Context())
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 
 		res, err := h.OrderClient.CloseOrder(ctx, &orderv1.CloseOrderRequest{UserId: userID})
 		if err != nil {
-			utils.Error(w, r, http.StatusInternalServerError, fmt.Errorf("failed to close order: %v", err))
+			utils.GRPCError(w, r, err)
 			return
 		}
 
 		cachedKey := fmt.Sprintf("listOrders:%s", userID)
-		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 		defer cancel()
 		h.redisClient.Del(ctx, cachedKey)
-		slog.Info("updated redis cached data")
+		slog.InfoContext(ctx, "updated redis cached data")
 
 		utils.Response(w, r, http.StatusOK, res)
 	}
 }
 func (h *OrderHandler) DeleteOrder() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID := r.URL.Query().Get("user_id")
-		if userID == "" {
-			utils.Error(w, r, http.StatusBadRequest, fmt.Errorf("missing user id"))
-			return
-		}
+		userID := middleware.UserID(r.Context())
 		cachedKey := fmt.Sprintf("listOrders:%s", userID)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 
 		res, err := h.OrderClient.DeleteOrder(ctx, &orderv1.DeleteOrderRequest{UserId: userID})
 		if err != nil {
-			utils.Error(w, r, http.StatusInternalServerError, fmt.Errorf("failed to delete order: %v", err))
+			utils.GRPCError(w, r, err)
 			return
 		}
 
-		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 		defer cancel()
 		h.redisClient.Del(ctx, cachedKey)
-		slog.Info("updated redis cached data")
+		slog.InfoContext(ctx, "updated redis cached data")
 
 		utils.Response(w, r, http.StatusOK, res)
 	}
 }
 func (h *OrderHandler) ListOrders() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID := r.URL.Query().Get("user_id")
-		if userID == "" {
-			utils.Error(w, r, http.StatusBadRequest, fmt.Errorf("missing user id"))
-			return
+		userID := middleware.UserID(r.Context())
+		if other := r.URL.Query().Get("user_id"); other != "" && middleware.HasRole(r.Context(), middleware.RoleStaff, middleware.RoleAdmin, middleware.RoleService) {
+			userID = other
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
 
 		cachedKey := fmt.Sprintf("listOrders:%s", userID)
@@ -199,26 +226,41 @@ func (h *OrderHandler) ListOrders() http.HandlerFunc {
 			var or orderv1.OrderListResponse
 
 			_ = json.Unmarshal([]byte(data), &or)
-			utils.Response(w, r, http.StatusOK, or)
-			slog.Info("get cached data from redis")
+			utils.Response(w, r, http.StatusOK, &or)
+			slog.InfoContext(ctx, "get cached data from redis")
 			return
 		}
 
-		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel = context.WithTimeout(requestContext(r), h.timeout)
 		defer cancel()
+		ctx = withAuth(ctx, r)
 
 		res, err := h.OrderClient.ListOrders(ctx, &orderv1.OrderListRequest{UserId: userID})
 		if err != nil {
-			utils.Error(w, r, http.StatusInternalServerError, fmt.Errorf("failed to list orders: %v", err))
+			utils.GRPCError(w, r, err)
 			return
 		}
 
-		ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancel = context.WithTimeout(requestContext(r), 60*time.Second)
 		defer cancel()
 		b, _ := json.Marshal(res)
 		h.redisClient.Set(ctx, cachedKey, b, time.Minute*5)
-		slog.Info("set data for redis")
+		slog.InfoContext(ctx, "set data for redis")
 
 		utils.Response(w, r, http.StatusOK, res)
 	}
 }
+
+// ownedOrder fetches an order and hides it from callers who neither own it
+// nor hold a staff role, so foreign order ids look the same as missing ones.
+func (h *OrderHandler) ownedOrder(ctx context.Context, r *http.Request, orderID string) (*orderv1.Order, error) {
+	order, err := h.OrderClient.GetOrder(ctx, &orderv1.GetOrderRequest{OrderId: orderID})
+	if err != nil {
+		return nil, err
+	}
+
+	if order.UserId != middleware.UserID(r.Context()) && !middleware.HasRole(r.Context(), middleware.RoleStaff, middleware.RoleAdmin, middleware.RoleService) {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
+	return order, nil
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/middleware"
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	"github.com/barcek2281/comics-store/pkg/validate"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+)
+
+func (h *AuthHandler) GetMe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.GetMe(ctx, &authv1.GetMeRequest{})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) UpdateProfile() http.HandlerFunc {
+	type Req struct {
+		DisplayName       string `json:"display_name"`
+		Phone             string `json:"phone"`
+		PreferredCurrency string `json:"preferred_currency"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.UpdateProfile(ctx, &authv1.UpdateProfileRequest{
+			DisplayName:       req.DisplayName,
+			Phone:             req.Phone,
+			PreferredCurrency: req.PreferredCurrency,
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) ChangeEmail() http.HandlerFunc {
+	type Req struct {
+		NewEmail string `json:"new_email" validate:"required,email,max=254"`
+		Password string `json:"password" validate:"required"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.ChangeEmail(ctx, &authv1.ChangeEmailRequest{
+			NewEmail: req.NewEmail,
+			Password: req.Password,
+		})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) DeleteAccount() http.HandlerFunc {
+	type Req struct {
+		Password string `json:"password"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.DeleteAccount(ctx, &authv1.DeleteAccountRequest{Password: req.Password})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		jti, ttl := middleware.TokenID(r.Context())
+		if err := h.revoked.Revoke(ctx, jti, ttl); err != nil {
+			h.log.WarnContext(r.Context(), "cannot revoke access token of deleted account", "error", err)
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
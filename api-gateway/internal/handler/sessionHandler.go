@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+)
+
+func (h *AuthHandler) ListSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.ListSessions(ctx, &authv1.ListSessionsRequest{})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+// RevokeSession ends one session in auth and flags it in Redis so its
+// access tokens stop working at the gateway straight away.
+func (h *AuthHandler) RevokeSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := r.PathValue("id")
+		if sid == "" {
+			utils.Error(w, r, http.StatusBadRequest, errors.New("missing session id"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.RevokeSession(ctx, &authv1.RevokeSessionRequest{SessionId: sid})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		h.Introspection.ForgetSession(sid)
+		if err := h.revoked.RevokeSession(ctx, sid); err != nil {
+			h.log.WarnContext(r.Context(), "cannot flag revoked session", "error", err)
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
+
+func (h *AuthHandler) RevokeAllSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(requestContext(r), h.timeout)
+		defer cancel()
+		ctx = withAuth(ctx, r)
+
+		res, err := h.AuthClient.RevokeAllSessions(ctx, &authv1.RevokeAllSessionsRequest{})
+		if err != nil {
+			utils.GRPCError(w, r, err)
+			return
+		}
+
+		for _, sid := range res.SessionIds {
+			h.Introspection.ForgetSession(sid)
+			if err := h.revoked.RevokeSession(ctx, sid); err != nil {
+				h.log.WarnContext(r.Context(), "cannot flag revoked session", "error", err)
+			}
+		}
+
+		utils.Response(w, r, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,139 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	refreshInterval = time.Minute * 10
+	// minRefreshGap stops tokens with unknown kids from hammering the auth
+	// service with JWKS requests.
+	minRefreshGap = time.Second * 30
+)
+
+// Cache keeps the auth service's public signing keys so tokens can be
+// verified locally. Keys are refetched periodically and whenever a token
+// names a kid we have not seen, so key rotation needs no gateway redeploy.
+type Cache struct {
+	log    *slog.Logger
+	client authv1.AuthClient
+
+	mu        sync.RWMutex
+	keys      map[string]ed25519.PublicKey
+	raw       []*authv1.JWK
+	fetchedAt time.Time
+}
+
+func New(log *slog.Logger, client authv1.AuthClient) *Cache {
+	return &Cache{
+		log:    log,
+		client: client,
+		keys:   make(map[string]ed25519.PublicKey),
+	}
+}
+
+// Keyfunc resolves the verification key for a token by its kid header.
+func (c *Cache) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
+		return nil, jwt.ErrInvalidKeyType
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no kid")
+	}
+
+	key, err := c.Key(context.Background(), kid)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (c *Cache) Key(ctx context.Context, kid string) (ed25519.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	stale := time.Since(c.fetchedAt) > refreshInterval
+	canRefresh := time.Since(c.fetchedAt) > minRefreshGap
+	c.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+	if !ok && !canRefresh {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		if ok {
+			c.log.Warn("cannot refresh jwks, using cached key", "error", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key, ok = c.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+// Keys returns the last fetched JWKS, fetching it if the cache is stale.
+func (c *Cache) Keys(ctx context.Context) ([]*authv1.JWK, error) {
+	c.mu.RLock()
+	stale := time.Since(c.fetchedAt) > refreshInterval
+	c.mu.RUnlock()
+
+	if stale {
+		if err := c.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.raw, nil
+}
+
+func (c *Cache) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := c.client.GetJWKS(ctx, &authv1.GetJWKSRequest{})
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(res.Keys))
+	for _, k := range res.Keys {
+		if k.Kty != "OKP" || k.Crv != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			c.log.Warn("skipping malformed jwk", "kid", k.Kid)
+			continue
+		}
+		keys[k.Kid] = ed25519.PublicKey(x)
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.raw = res.Keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	c.log.Info("refreshed jwks", "keys", len(keys))
+	return nil
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/barcek2281/comics-store/pkg/clientip"
+)
+
+// ClientIP resolves the end client's address once per request, trusting
+// X-Forwarded-For only when the connection comes from a trusted proxy,
+// and stores it in the context for utils.ClientIP.
+func ClientIP(resolver *clientip.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolver.Resolve(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+			next.ServeHTTP(w, r.WithContext(clientip.WithIP(r.Context(), ip)))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/barcek2281/comics-store/api-gateway/internal/cache"
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	maxIdempotencyKeyLen = 255
+	maxIdempotentBody    = 1 << 20
+)
+
+// Idempotency makes unsafe requests that carry an Idempotency-Key header
+// safe to retry. The first request with a key runs normally and, if it
+// succeeds, its response is stored; retries with the same key and the
+// same method, path and body get that response replayed. Reusing a key
+// for a different request is rejected with 422.
+//
+// It must be wrapped by AuthMiddleware: keys are scoped to the
+// authenticated user or API key, so two callers cannot collide, and
+// unauthenticated requests are passed through untouched.
+//
+// Only 2xx responses are stored, and never those marked
+// "Cache-Control: no-store" because they carry credentials. Otherwise the
+// key is released and a retry runs again, e.g. once stock was replenished.
+func (m *Middleware) Idempotency(store *cache.IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			authenticated := UserID(r.Context()) != "" || IsService(r.Context())
+			if key == "" || !isUnsafe(r.Method) || !authenticated {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				utils.Error(w, r, http.StatusBadRequest, errors.New("idempotency key is too long"))
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+			if err != nil {
+				utils.Error(w, r, http.StatusRequestEntityTooLarge, errors.New("request body is too large"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scoped := clientKey(r) + "|" + key
+			fp := fingerprint(r, body)
+			stored, err := store.Begin(r.Context(), scoped, fp)
+			switch {
+			case errors.Is(err, cache.ErrIdempotencyKeyReused):
+				utils.Error(w, r, http.StatusUnprocessableEntity, err)
+				return
+			case errors.Is(err, cache.ErrIdempotencyInProgress):
+				utils.Error(w, r, http.StatusConflict, err)
+				return
+			case err != nil:
+				m.log.Warn("cannot check idempotency key", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			case stored != nil:
+				replay(w, stored)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// The outcome must be recorded even if the client went away.
+			ctx := context.WithoutCancel(r.Context())
+			if rec.status < 200 || rec.status > 299 || noStore(w.Header()) {
+				if err := store.Release(ctx, scoped); err != nil {
+					m.log.Warn("cannot release idempotency key", "error", err)
+				}
+				return
+			}
+			err = store.Complete(ctx, scoped, cache.StoredResponse{
+				Fingerprint: fp,
+				Status:      rec.status,
+				Header:      http.Header{"Content-Type": w.Header().Values("Content-Type")},
+				Body:        rec.body.Bytes(),
+			})
+			if err != nil {
+				m.log.Warn("cannot store idempotent response", "error", err)
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, stored *cache.StoredResponse) {
+	for k, v := range stored.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func noStore(h http.Header) bool {
+	for _, v := range h.Values("Cache-Control") {
+		if strings.Contains(strings.ToLower(v), "no-store") {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnsafe(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder passes a response through while keeping a copy of its
+// status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
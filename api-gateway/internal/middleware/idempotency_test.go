@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/barcek2281/comics-store/api-gateway/internal/cache"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIdempotency(t *testing.T) {
+	asUser := func(uid float64) func(r *http.Request) *http.Request {
+		return func(r *http.Request) *http.Request {
+			return r.WithContext(context.WithValue(r.Context(), claimsKey, jwt.MapClaims{"uid": uid}))
+		}
+	}
+	anonymous := func(r *http.Request) *http.Request { return r }
+
+	tests := []struct {
+		name      string
+		first     func(r *http.Request) *http.Request
+		second    func(r *http.Request) *http.Request
+		noStore   bool
+		wantCalls int
+	}{
+		{"replays for the same user", asUser(1), asUser(1), false, 1},
+		{"scoped by user", asUser(1), asUser(2), false, 2},
+		{"ignores unauthenticated callers", anonymous, anonymous, false, 2},
+		{"never stores no-store responses", asUser(1), asUser(1), true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+			store := cache.NewIdempotencyStore(client, time.Hour, time.Minute)
+			m := &Middleware{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+			calls := 0
+			h := m.Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if tt.noStore {
+					w.Header().Set("Cache-Control", "no-store")
+				}
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, `{"ok":true}`)
+			}))
+
+			for _, as := range []func(r *http.Request) *http.Request{tt.first, tt.second} {
+				r := httptest.NewRequest(http.MethodPost, "/order/create", strings.NewReader(`{"id":1}`))
+				r.Header.Set(IdempotencyKeyHeader, "k")
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, as(r))
+				if w.Code != http.StatusCreated {
+					t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -1,33 +1,380 @@
 package middleware
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/barcek2281/comics-store/api-gateway/internal/cache"
+	"github.com/barcek2281/comics-store/api-gateway/internal/jwks"
+	"github.com/barcek2281/comics-store/api-gateway/internal/utils"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("secret")
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+	RoleService  = "service"
+)
+
+const (
+	ScopeInventoryWrite = "inventory:write"
+	ScopeOrdersRead     = "orders:read"
+)
+
+type ctxKey string
 
-func AuthMiddleware(next http.Handler) http.Handler {
+const (
+	claimsKey ctxKey = "claims"
+	tokenKey  ctxKey = "token"
+	apiKeyKey ctxKey = "api_key"
+)
+
+type Middleware struct {
+	log        *slog.Logger
+	revoked    *cache.RevocationList
+	keys       *jwks.Cache
+	introspect *cache.IntrospectionCache
+	failOpen   bool
+}
+
+// New builds the middleware. failOpen lets tokens through on their
+// signature alone when the auth service cannot introspect them.
+func New(log *slog.Logger, revoked *cache.RevocationList, keys *jwks.Cache, introspect *cache.IntrospectionCache, failOpen bool) *Middleware {
+	return &Middleware{
+		log:        log,
+		revoked:    revoked,
+		keys:       keys,
+		introspect: introspect,
+		failOpen:   failOpen,
+	}
+}
+
+// AuthMiddleware authenticates the request either with the JWT in the
+// "token" header or with the API key in the "X-API-Key" header.
+func (m *Middleware) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			m.apiKeyAuth(w, r, key, next)
+			return
+		}
+
 		cookie := r.Header.Get("token")
 		if cookie == "" {
-			http.Error(w, "unauthorized: no token cookie", http.StatusUnauthorized)
+			utils.Error(w, r, http.StatusUnauthorized, errors.New("unauthorized: no token cookie"))
 			return
 		}
 
-		token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrInvalidKeyType
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(cookie, claims, m.keys.Keyfunc, jwt.WithValidMethods([]string{"EdDSA"}))
+
+		if err != nil || !token.Valid {
+			utils.Error(w, r, http.StatusUnauthorized, errors.New("unauthorized: invalid token"))
+			return
+		}
+
+		if _, ok := claims["purpose"]; ok {
+			utils.Error(w, r, http.StatusUnauthorized, errors.New("unauthorized: not an access token"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsKey, claims)
+		ctx = context.WithValue(ctx, tokenKey, cookie)
+		if UserID(ctx) == "" {
+			utils.Error(w, r, http.StatusUnauthorized, errors.New("unauthorized: token has no uid"))
+			return
+		}
+
+		if jti, _ := claims["jti"].(string); jti != "" {
+			revoked, err := m.revoked.IsRevoked(r.Context(), jti)
+			if err != nil {
+				m.log.Warn("cannot check token revocation", "error", err)
+			}
+			if revoked {
+				utils.Error(w, r, http.StatusUnauthorized, errors.New("unauthorized: token revoked"))
+				return
 			}
-			return jwtSecret, nil
+		}
+
+		if sid := SessionID(ctx); sid != "" {
+			revoked, err := m.revoked.IsSessionRevoked(r.Context(), sid)
+			if err != nil {
+				m.log.Warn("cannot check session revocation", "error", err)
+			}
+			if revoked {
+				utils.Error(w, r, http.StatusUnauthorized, errors.New("unauthorized: session revoked"))
+				return
+			}
+		}
+
+		disabled, err := m.revoked.IsUserDisabled(r.Context(), UserID(ctx))
+		if err != nil {
+			m.log.Warn("cannot check disabled user", "error", err)
+		}
+		if disabled {
+			utils.Error(w, r, http.StatusForbidden, errors.New("forbidden: account disabled"))
+			return
+		}
+
+		// The signature is already checked locally; introspection adds the
+		// auth service's view of revocation. If auth is unreachable the
+		// request is refused unless the gateway is configured to fail open.
+		res, err := m.introspect.Introspect(r.Context(), cookie)
+		if err != nil {
+			m.log.Warn("cannot introspect token", "error", err)
+			if !m.failOpen {
+				utils.Error(w, r, http.StatusServiceUnavailable, errors.New("service unavailable: cannot verify token"))
+				return
+			}
+		} else if !res.Active {
+			utils.Error(w, r, http.StatusUnauthorized, errors.New("unauthorized: token revoked"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// apiKeyAuth resolves an API key to a service principal. Unlike tokens, a
+// key cannot be checked locally, so an unreachable auth service fails the
+// request.
+func (m *Middleware) apiKeyAuth(w http.ResponseWriter, r *http.Request, key string, next http.Handler) {
+	res, err := m.introspect.IntrospectAPIKey(r.Context(), key)
+	if err != nil {
+		m.log.Warn("cannot introspect api key", "error", err)
+		utils.Error(w, r, http.StatusServiceUnavailable, errors.New("service unavailable: cannot verify api key"))
+		return
+	}
+	if !res.Active {
+		utils.Error(w, r, http.StatusUnauthorized, errors.New("unauthorized: invalid api key"))
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"service_account": res.ServiceAccount,
+		"roles":           toInterfaces(res.Roles),
+		"scopes":          toInterfaces(res.Scopes),
+	}
+	ctx := context.WithValue(r.Context(), claimsKey, claims)
+	ctx = context.WithValue(ctx, apiKeyKey, key)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireScope limits API keys to routes matching one of their scopes.
+// Users are not affected; their access is governed by roles alone. It must
+// be wrapped by AuthMiddleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsService(r.Context()) && !contains(Scopes(r.Context()), scope) {
+				utils.Error(w, r, http.StatusForbidden, errors.New("forbidden: missing scope"))
+				return
+			}
+			next.ServeHTTP(w, r)
 		})
+	}
+}
 
-		if err != nil || !token.Valid {
-			http.Error(w, "unauthorized: invalid token", http.StatusUnauthorized)
+// RequireRoles lets the request through only if the token carries at least
+// one of the given roles. It must be wrapped by AuthMiddleware.
+func RequireRoles(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasRole(r.Context(), roles...) {
+				utils.Error(w, r, http.StatusForbidden, errors.New("forbidden: missing role"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireVerifiedEmail rejects callers whose token says their email has not
+// been confirmed yet. It must be wrapped by AuthMiddleware.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if verified, _ := Claims(r.Context())["email_verified"].(bool); !verified {
+			utils.Error(w, r, http.StatusForbidden, errors.New("forbidden: email not verified"))
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
+// RequireMFA rejects tokens that were not obtained with a second factor.
+// API keys are non-interactive and pass. It must be wrapped by
+// AuthMiddleware.
+func RequireMFA(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mfa, _ := Claims(r.Context())["mfa"].(bool); !mfa && !IsService(r.Context()) {
+			utils.Error(w, r, http.StatusForbidden, errors.New("forbidden: two-factor authentication required"))
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RateLimits holds the limit of every route pattern; routes without an
+// entry share Default.
+type RateLimits struct {
+	Default cache.Limit
+	Routes  map[string]cache.Limit
+}
+
+func (l RateLimits) For(route string) cache.Limit {
+	if limit, ok := l.Routes[route]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// RateLimit counts every request to route against a bucket per client and
+// rejects it with 429 once the bucket is empty. Inside AuthMiddleware it
+// counts per user or API key, otherwise per client IP; see clientKey.
+func RateLimit(limiter *cache.RateLimiter, route string, limit cache.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.Allow(r.Context(), route+"|"+clientKey(r), limit)
+
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+				utils.Error(w, r, http.StatusTooManyRequests, errors.New("rate limit exceeded"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the caller: the hash of the API key or the uid that
+// AuthMiddleware verified, or else the client IP as resolved from trusted
+// proxies.
+func clientKey(r *http.Request) string {
+	if key := APIKey(r.Context()); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:])
+	}
+	if uid := UserID(r.Context()); uid != "" {
+		return "user:" + uid
+	}
+	return "ip:" + utils.ClientIP(r)
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func Claims(ctx context.Context) jwt.MapClaims {
+	claims, _ := ctx.Value(claimsKey).(jwt.MapClaims)
+	return claims
+}
+
+// Token returns the raw access token the request was authenticated with.
+func Token(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey).(string)
+	return token
+}
+
+// APIKey returns the API key the request was authenticated with.
+func APIKey(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyKey).(string)
+	return key
+}
+
+// IsService reports whether the caller is a service account using an API
+// key rather than a user.
+func IsService(ctx context.Context) bool {
+	return APIKey(ctx) != ""
+}
+
+// UserID returns the verified uid claim of the caller, or "" when the
+// request did not pass through AuthMiddleware.
+func UserID(ctx context.Context) string {
+	switch uid := Claims(ctx)["uid"].(type) {
+	case float64:
+		return fmt.Sprintf("%d", int64(uid))
+	case string:
+		return uid
+	default:
+		return ""
+	}
+}
+
+// SessionID returns the sid claim of the caller's token.
+func SessionID(ctx context.Context) string {
+	sid, _ := Claims(ctx)["sid"].(string)
+	return sid
+}
+
+func HasRole(ctx context.Context, roles ...string) bool {
+	for _, role := range Roles(ctx) {
+		if contains(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenID returns the jti claim and how long the token is still valid for.
+func TokenID(ctx context.Context) (string, time.Duration) {
+	claims := Claims(ctx)
+	jti, _ := claims["jti"].(string)
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return jti, 0
+	}
+	return jti, time.Until(exp.Time)
+}
+
+func Roles(ctx context.Context) []string {
+	return claimStrings(ctx, "roles")
+}
+
+// Scopes returns the scopes of an API key caller.
+func Scopes(ctx context.Context) []string {
+	return claimStrings(ctx, "scopes")
+}
+
+func claimStrings(ctx context.Context, name string) []string {
+	raw, ok := Claims(ctx)[name].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	out := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func toInterfaces(s []string) []interface{} {
+	out := make([]interface{}, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
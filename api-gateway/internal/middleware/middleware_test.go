@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/barcek2281/comics-store/pkg/clientip"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        func(ctx context.Context) context.Context
+		header     string
+		wantPrefix string
+		want       string
+	}{
+		{
+			name: "api key",
+			ctx: func(ctx context.Context) context.Context {
+				return context.WithValue(ctx, apiKeyKey, "csk_0a1b2c3d_secret")
+			},
+			wantPrefix: "key:",
+		},
+		{
+			name: "verified user",
+			ctx: func(ctx context.Context) context.Context {
+				return context.WithValue(ctx, claimsKey, jwt.MapClaims{"uid": float64(42)})
+			},
+			want: "user:42",
+		},
+		{
+			name: "resolved client ip",
+			ctx: func(ctx context.Context) context.Context {
+				return clientip.WithIP(ctx, "203.0.113.7")
+			},
+			want: "ip:203.0.113.7",
+		},
+		{
+			name:   "unverified token is not trusted",
+			ctx:    func(ctx context.Context) context.Context { return clientip.WithIP(ctx, "203.0.113.7") },
+			header: "forged",
+			want:   "ip:203.0.113.7",
+		},
+		{
+			name: "remote address without resolver",
+			ctx:  func(ctx context.Context) context.Context { return ctx },
+			want: "ip:192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("token", tt.header)
+			}
+			r = r.WithContext(tt.ctx(r.Context()))
+
+			got := clientKey(r)
+			if tt.wantPrefix != "" {
+				if !strings.HasPrefix(got, tt.wantPrefix) || strings.Contains(got, "secret") {
+					t.Errorf("clientKey() = %q, want a hashed key with prefix %q", got, tt.wantPrefix)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("clientKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
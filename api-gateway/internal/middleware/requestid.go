@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/barcek2281/comics-store/pkg/requestid"
+)
+
+// RequestID tags the request with the caller's X-Request-ID, or a new id
+// if it sent none or an unusable one, echoes it in the response and logs
+// the request once it is done. Handlers pass the id on to upstream
+// services through the request context.
+func (m *Middleware) RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestid.Sanitize(r.Header.Get(requestid.Header))
+		ctx := requestid.WithID(r.Context(), id)
+		w.Header().Set(requestid.Header, id)
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		m.log.InfoContext(ctx, "request handled",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
@@ -1,54 +1,200 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/barcek2281/comics-store/api-gateway/internal/cache"
+	"github.com/barcek2281/comics-store/api-gateway/internal/configs"
 	"github.com/barcek2281/comics-store/api-gateway/internal/handler"
+	"github.com/barcek2281/comics-store/api-gateway/internal/jwks"
 	"github.com/barcek2281/comics-store/api-gateway/internal/middleware"
+	"github.com/barcek2281/comics-store/pkg/clientip"
+	"github.com/go-redis/redis/v8"
 )
 
 type Server struct {
-	log *slog.Logger
-	port   int
-	mux    *http.ServeMux
-	authHandler *handler.AuthHandler
+	log              *slog.Logger
+	port             int
+	shutdownTimeout  time.Duration
+	redisClient      *redis.Client
+	mux              *http.ServeMux
+	authHandler      *handler.AuthHandler
 	inventoryHandler *handler.InventoryHandler
-	orderHanler *handler.OrderHandler
+	orderHanler      *handler.OrderHandler
+	mw               *middleware.Middleware
+	keys             *jwks.Cache
+	limiter          *cache.RateLimiter
+	rateLimit        configs.RateLimit
+	limits           middleware.RateLimits
+	idempotency      *cache.IdempotencyStore
+	clientIPs        *clientip.Resolver
 }
 
-func NewServer(log *slog.Logger, port int) *Server {
+func NewServer(log *slog.Logger, cfg *configs.Config, clientIPs *clientip.Resolver) *Server {
+	redisClient := cache.NewRedisClient(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+	revoked := cache.NewRevocationList(redisClient)
+	authHandler := handler.NewAuthHandler(log, cfg.Upstreams.Auth, cfg.RequestTimeout, redisClient, revoked)
+	keys := jwks.New(log, authHandler.AuthClient)
+
+	limits := middleware.RateLimits{
+		Default: cache.Limit(cfg.RateLimit.Default),
+		Routes:  make(map[string]cache.Limit, len(cfg.RateLimit.Routes)),
+	}
+	for route, limit := range cfg.RateLimit.Routes {
+		limits.Routes[route] = cache.Limit(limit)
+	}
+
 	return &Server{
-		log: log,
-		port: port,
-		mux:  http.NewServeMux(),
-		authHandler: handler.NewAuthHandler(log, 50051),
-		inventoryHandler: handler.NewInventoryHandler(log, 50052),
-		orderHanler: handler.NewOrderHandler(log, 50053),
+		log:              log,
+		port:             cfg.Port,
+		shutdownTimeout:  cfg.ShutdownTimeout,
+		redisClient:      redisClient,
+		mux:              http.NewServeMux(),
+		mw:               middleware.New(log, revoked, keys, authHandler.Introspection, cfg.Introspection.FailOpen),
+		keys:             keys,
+		authHandler:      authHandler,
+		inventoryHandler: handler.NewInventoryHandler(log, cfg.Upstreams.Inventory, cfg.RequestTimeout, redisClient),
+		orderHanler:      handler.NewOrderHandler(log, cfg.Upstreams.Order, cfg.RequestTimeout, redisClient),
+		limiter:          cache.NewRateLimiter(log, redisClient),
+		rateLimit:        cfg.RateLimit,
+		limits:           limits,
+		idempotency:      cache.NewIdempotencyStore(redisClient, cfg.Idempotency.TTL, 2*cfg.RequestTimeout),
+		clientIPs:        clientIPs,
 	}
 }
 
-func (s *Server) Run() error {
+// Run serves until ctx is cancelled, then stops accepting connections and
+// waits up to the shutdown timeout for in-flight requests before closing
+// the upstream connections.
+func (s *Server) Run(ctx context.Context) error {
 	s.configure()
 
-	return http.ListenAndServe(fmt.Sprintf(":%d", s.port), s.mux)
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", s.port), Handler: s.handler()}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.log.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+
+	return errors.Join(err, s.close())
+}
+
+// handler wraps the mux in the middleware that applies to every route.
+// The request id is assigned first so every response, including
+// rejections, carries it; the client address is resolved next so the
+// rate limiter and the handlers agree on it.
+func (s *Server) handler() http.Handler {
+	return s.mw.RequestID(middleware.ClientIP(s.clientIPs)(s.mux))
+}
+
+// handle registers a public route, rate limited per client IP.
+func (s *Server) handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, s.limit(pattern, h))
+}
+
+// handleAuth registers a route for authenticated callers, or only for
+// those with one of roles. The rate limit runs after AuthMiddleware, so
+// it counts per user or API key.
+func (s *Server) handleAuth(pattern string, h http.Handler, roles ...string) {
+	if len(roles) > 0 {
+		h = middleware.RequireRoles(roles...)(h)
+	}
+	s.mux.Handle(pattern, s.mw.AuthMiddleware(s.limit(pattern, h)))
+}
+
+// idempotent lets retries of a mutation with the same Idempotency-Key
+// replay the first response. Register it through handleAuth.
+func (s *Server) idempotent(h http.Handler) http.Handler {
+	return s.mw.Idempotency(s.idempotency)(h)
+}
+
+// limit applies the rate limit configured for pattern, the route's mux
+// pattern, to h.
+func (s *Server) limit(pattern string, h http.Handler) http.Handler {
+	if !s.rateLimit.Enabled {
+		return h
+	}
+	return middleware.RateLimit(s.limiter, pattern, s.limits.For(pattern))(h)
+}
+
+func (s *Server) close() error {
+	var errs []error
+	for _, c := range []interface{ Close() error }{s.authHandler, s.inventoryHandler, s.orderHanler, s.limiter} {
+		errs = append(errs, c.Close())
+	}
+	errs = append(errs, s.redisClient.Close())
+	return errors.Join(errs...)
 }
 
 func (s *Server) configure() {
-	s.mux.Handle("POST /auth/login", s.authHandler.Login())
-	s.mux.Handle("POST /auth/register", s.authHandler.Register())
-
-	s.mux.Handle("POST /inventory/create", s.inventoryHandler.Create())
-	s.mux.Handle("DELETE /inventory/delete", middleware.AuthMiddleware(s.inventoryHandler.Delete()))
-	s.mux.Handle("PUT /inventory/update", s.inventoryHandler.Update())
-	s.mux.Handle("GET /inventory/list", s.inventoryHandler.List())
-	s.mux.Handle("GET /inventory/get", s.inventoryHandler.Get())
-
-	s.mux.Handle("POST /order/create", s.orderHanler.CreateOrder())
-	s.mux.Handle("GET /order/get", s.orderHanler.GetOrder())
-	s.mux.Handle("PUT /order/update", s.orderHanler.UpdateOrder())
-	s.mux.Handle("POST /order/close", s.orderHanler.CloseOrder())
-	s.mux.Handle("GET /order/list", s.orderHanler.ListOrders())
-	s.mux.Handle("DELETE /order/delete", s.orderHanler.DeleteOrder())
+	s.handle("GET /.well-known/jwks.json", handler.JWKS(s.keys))
+
+	s.handle("POST /auth/login", s.authHandler.Login())
+	s.handle("POST /auth/register", s.authHandler.Register())
+	s.handle("GET /auth/verify-email", handler.ConfirmPage("Verify your email", "Verify email"))
+	s.handle("POST /auth/verify-email", s.authHandler.VerifyEmail())
+	s.handle("POST /auth/magic-link", s.authHandler.RequestMagicLink())
+	s.handle("GET /auth/magic-link/consume", handler.ConfirmPage("Sign in", "Sign in"))
+	s.handle("POST /auth/magic-link/consume", s.authHandler.ConsumeMagicLink())
+	s.handle("GET /auth/oidc/{provider}/start", s.authHandler.OIDCStart())
+	s.handle("GET /auth/oidc/{provider}/callback", s.authHandler.OIDCCallback())
+	s.handle("POST /auth/resend-verification", s.authHandler.ResendVerification())
+	s.handle("POST /auth/password/forgot", s.authHandler.RequestPasswordReset())
+	s.handle("GET /auth/password/reset", handler.ResetPasswordPage())
+	s.handle("POST /auth/password/reset", s.authHandler.ResetPassword())
+	s.handleAuth("POST /auth/password/change", s.authHandler.ChangePassword())
+	s.handleAuth("POST /auth/2fa/enroll", s.authHandler.EnrollTOTP())
+	s.handleAuth("POST /auth/2fa/confirm", s.authHandler.ConfirmTOTP())
+	s.handle("POST /auth/2fa/verify", s.authHandler.VerifySecondFactor())
+	s.handle("POST /auth/refresh", s.authHandler.Refresh())
+	s.handleAuth("POST /auth/logout", s.authHandler.Logout())
+
+	s.handleAuth("GET /me", s.authHandler.GetMe())
+	s.handleAuth("PUT /me", s.authHandler.UpdateProfile())
+	s.handleAuth("POST /me/email", s.authHandler.ChangeEmail())
+	s.handleAuth("DELETE /me", s.authHandler.DeleteAccount())
+	s.handleAuth("GET /me/sessions", s.authHandler.ListSessions())
+	s.handleAuth("DELETE /me/sessions", s.authHandler.RevokeAllSessions())
+	s.handleAuth("DELETE /me/sessions/{id}", s.authHandler.RevokeSession())
+
+	s.handleAuth("POST /admin/users/unlock", s.authHandler.UnlockAccount(), middleware.RoleAdmin)
+	s.handleAuth("GET /admin/users", s.authHandler.ListUsers(), middleware.RoleAdmin)
+	s.handleAuth("GET /admin/users/{id}", s.authHandler.GetUser(), middleware.RoleAdmin)
+	s.handleAuth("PUT /admin/users/{id}/roles", s.authHandler.SetRoles(), middleware.RoleAdmin)
+	s.handleAuth("POST /admin/users/{id}/disable", s.authHandler.DisableUser(), middleware.RoleAdmin)
+	s.handleAuth("POST /admin/users/{id}/enable", s.authHandler.EnableUser(), middleware.RoleAdmin)
+	s.handleAuth("GET /admin/audit-events", s.authHandler.ListAuditEvents(), middleware.RoleAdmin)
+	s.handleAuth("POST /admin/api-keys", s.authHandler.CreateAPIKey(), middleware.RoleAdmin)
+	s.handleAuth("GET /admin/api-keys", s.authHandler.ListAPIKeys(), middleware.RoleAdmin)
+	s.handleAuth("DELETE /admin/api-keys/{id}", s.authHandler.RevokeAPIKey(), middleware.RoleAdmin)
+
+	inventoryWrite := middleware.RequireScope(middleware.ScopeInventoryWrite)
+	s.handleAuth("POST /inventory/create", s.idempotent(inventoryWrite(middleware.RequireMFA(s.inventoryHandler.Create()))), middleware.RoleStaff, middleware.RoleAdmin, middleware.RoleService)
+	s.handleAuth("DELETE /inventory/delete", s.idempotent(inventoryWrite(middleware.RequireMFA(s.inventoryHandler.Delete()))), middleware.RoleStaff, middleware.RoleAdmin, middleware.RoleService)
+	s.handleAuth("PUT /inventory/update", s.idempotent(inventoryWrite(middleware.RequireMFA(s.inventoryHandler.Update()))), middleware.RoleStaff, middleware.RoleAdmin, middleware.RoleService)
+	s.handle("GET /inventory/list", s.inventoryHandler.List())
+	s.handle("GET /inventory/get", s.inventoryHandler.Get())
+
+	s.handleAuth("POST /order/create", s.idempotent(middleware.RequireVerifiedEmail(s.orderHanler.CreateOrder())), middleware.RoleCustomer)
+	ordersRead := middleware.RequireScope(middleware.ScopeOrdersRead)
+	s.handleAuth("GET /order/get", ordersRead(s.orderHanler.GetOrder()), middleware.RoleCustomer, middleware.RoleStaff, middleware.RoleAdmin, middleware.RoleService)
+	s.handleAuth("PUT /order/update", s.idempotent(s.orderHanler.UpdateOrder()), middleware.RoleCustomer)
+	s.handleAuth("POST /order/close", s.idempotent(s.orderHanler.CloseOrder()), middleware.RoleCustomer)
+	s.handleAuth("GET /order/list", ordersRead(s.orderHanler.ListOrders()), middleware.RoleCustomer, middleware.RoleStaff, middleware.RoleAdmin, middleware.RoleService)
+	s.handleAuth("DELETE /order/delete", s.idempotent(s.orderHanler.DeleteOrder()), middleware.RoleCustomer)
 }
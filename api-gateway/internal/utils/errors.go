@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/barcek2281/comics-store/pkg/requestid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorBody is the envelope every error response is wrapped in:
+//
+//	{"error": {"code": "not_found", "message": "...", "request_id": "..."}}
+type ErrorBody struct {
+	Code            string           `json:"code"`
+	Message         string           `json:"message"`
+	FieldViolations []FieldViolation `json:"field_violations,omitempty"`
+	RequestID       string           `json:"request_id,omitempty"`
+}
+
+// FieldViolation points at one bad field of the request.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+type errorEnvelope struct {
+	Error ErrorBody `json:"error"`
+}
+
+// grpcToHTTP is the single place gRPC codes are translated to HTTP.
+var grpcToHTTP = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusPreconditionFailed,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+var codeNames = map[codes.Code]string{
+	codes.Canceled:           "canceled",
+	codes.Unknown:            "unknown",
+	codes.InvalidArgument:    "invalid_argument",
+	codes.DeadlineExceeded:   "deadline_exceeded",
+	codes.NotFound:           "not_found",
+	codes.AlreadyExists:      "already_exists",
+	codes.PermissionDenied:   "permission_denied",
+	codes.ResourceExhausted:  "resource_exhausted",
+	codes.FailedPrecondition: "failed_precondition",
+	codes.Aborted:            "aborted",
+	codes.OutOfRange:         "out_of_range",
+	codes.Unimplemented:      "unimplemented",
+	codes.Internal:           "internal",
+	codes.Unavailable:        "unavailable",
+	codes.DataLoss:           "data_loss",
+	codes.Unauthenticated:    "unauthenticated",
+}
+
+// HTTPStatus returns the HTTP status a gRPC code is reported as.
+func HTTPStatus(c codes.Code) int {
+	if s, ok := grpcToHTTP[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// Error writes err in the error envelope with the given HTTP status. Use
+// it for failures the gateway detects itself; upstream errors go through
+// GRPCError.
+func Error(w http.ResponseWriter, r *http.Request, code int, err error) {
+	writeError(w, r, code, ErrorBody{
+		Code:    codeForStatus(code),
+		Message: err.Error(),
+	})
+}
+
+// GRPCError translates an error returned by an upstream service, or any
+// other error carrying a status such as validate.Errors, into an HTTP
+// response. The status message and any BadRequest field violations
+// are passed through; a ResourceExhausted status also sets Retry-After
+// from its RetryInfo. Errors that are not statuses, and Internal/Unknown
+// statuses, are reported without their text so storage details do not
+// leak to clients.
+func GRPCError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	body := ErrorBody{Code: codeName(st.Code()), Message: st.Message()}
+
+	switch st.Code() {
+	case codes.Unknown, codes.Internal, codes.DataLoss:
+		body.Code = codeName(codes.Internal)
+		body.Message = "internal error"
+	case codes.Unavailable:
+		body.Message = "upstream service unavailable"
+	case codes.DeadlineExceeded:
+		body.Message = "upstream service timed out"
+	}
+
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				body.FieldViolations = append(body.FieldViolations, FieldViolation{
+					Field:       v.GetField(),
+					Description: v.GetDescription(),
+				})
+			}
+		case *errdetails.RetryInfo:
+			secs := int(math.Ceil(d.GetRetryDelay().AsDuration().Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+		}
+	}
+
+	writeError(w, r, HTTPStatus(st.Code()), body)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, code int, body ErrorBody) {
+	body.RequestID = RequestID(r)
+	Response(w, r, code, errorEnvelope{Error: body})
+}
+
+// RequestID returns the id the request is tracked by, if any.
+func RequestID(r *http.Request) string {
+	return requestid.FromContext(r.Context())
+}
+
+func codeName(c codes.Code) string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return codeNames[codes.Unknown]
+}
+
+// codeForStatus picks the envelope code for an error the gateway raised
+// itself, so clients can switch on the same codes either way.
+func codeForStatus(code int) string {
+	switch code {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return codeName(codes.InvalidArgument)
+	case http.StatusUnauthorized:
+		return codeName(codes.Unauthenticated)
+	case http.StatusForbidden:
+		return codeName(codes.PermissionDenied)
+	case http.StatusNotFound:
+		return codeName(codes.NotFound)
+	case http.StatusConflict:
+		return codeName(codes.AlreadyExists)
+	case http.StatusPreconditionFailed:
+		return codeName(codes.FailedPrecondition)
+	case http.StatusTooManyRequests:
+		return codeName(codes.ResourceExhausted)
+	case http.StatusNotImplemented:
+		return codeName(codes.Unimplemented)
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codeName(codes.Unavailable)
+	case http.StatusGatewayTimeout:
+		return codeName(codes.DeadlineExceeded)
+	default:
+		return codeName(codes.Internal)
+	}
+}
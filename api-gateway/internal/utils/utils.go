@@ -2,12 +2,11 @@ package utils
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
-)
 
-func Error(w http.ResponseWriter, r *http.Request, code int, err error) {
-	Response(w, r, code, map[string]string{"error": err.Error()})
-}
+	"github.com/barcek2281/comics-store/pkg/clientip"
+)
 
 func Response(w http.ResponseWriter, r *http.Request, code int, data interface{}) {
 	w.Header().Add("Content-Type", "application/json")
@@ -16,3 +15,16 @@ func Response(w http.ResponseWriter, r *http.Request, code int, data interface{}
 		json.NewEncoder(w).Encode(data)
 	}
 }
+
+// ClientIP returns the originating client address as resolved by the
+// ClientIP middleware, falling back to the connection's remote address.
+func ClientIP(r *http.Request) string {
+	if ip := clientip.FromContext(r.Context()); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
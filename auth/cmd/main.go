@@ -1,33 +1,220 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/barcek2281/comics-store/auth/internal/audit"
+	"github.com/barcek2281/comics-store/auth/internal/configs"
 	grpcserver "github.com/barcek2281/comics-store/auth/internal/grpcServer"
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/lib/password"
+	"github.com/barcek2281/comics-store/auth/internal/lockout"
+	"github.com/barcek2281/comics-store/auth/internal/mail"
+	"github.com/barcek2281/comics-store/auth/internal/oidc"
 	sqlite1488 "github.com/barcek2281/comics-store/auth/internal/storage/sqlite3"
+	"github.com/barcek2281/comics-store/auth/migrations"
+	"github.com/barcek2281/comics-store/pkg/migrator"
+	"github.com/barcek2281/comics-store/pkg/requestid"
 	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"github.com/go-redis/redis/v8"
+	"github.com/nats-io/nats.go"
+	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc"
 )
 
+var (
+	configPath  string
+	printConfig bool
+)
+
+func init() {
+	flag.StringVar(&configPath, "config-path", "./configs/local.yaml", "config path")
+	flag.BoolVar(&printConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+}
+
 func main() {
-	lis, err := net.Listen("tcp", ":50051")
+	flag.Parse()
+
+	cfg := configs.MustLoad(configPath)
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("cannot print config: %v", err)
+		}
+		return
+	}
+
+	level, _ := cfg.Level()
+	slog.SetDefault(slog.New(requestid.NewHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))))
+
+	db, err := sql.Open("sqlite3", cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("error to open database: %v", err)
+	}
+	m := migrator.New(db, migrations.FS)
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "migrate" {
+			log.Fatalf("unknown command %q", args[0])
+		}
+		err := m.Run(context.Background(), os.Stdout, args[1:])
+		db.Close()
+		if err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		log.Fatalf("error to apply migrations: %v", err)
+	}
+	db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
 	if err != nil {
-		log.Fatalf("failed to listen on port 50051: %v", err)
+		log.Fatalf("failed to listen on port %d: %v", cfg.Port, err)
 	}
 
-	store, err := sqlite1488.NewStorage("./storage/user.db")
+	store, err := sqlite1488.NewStorage(cfg.StoragePath)
 	if err != nil {
 		log.Fatalf("error to load storage: %v", err)
 	}
 
-	g := grpcserver.New(store)
+	keyring := jwt.NewKeyring(store, cfg.Keys.RotateEvery, cfg.Keys.RetainFor)
+	if err := keyring.Load(ctx); err != nil {
+		log.Fatalf("error to load signing keys: %v", err)
+	}
+
+	// Background workers stop with ctx; the store is closed only after
+	// they have returned.
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		keyring.Run(ctx)
+	}()
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:        cfg.Redis.Addr,
+		Password:    cfg.Redis.Password,
+		DialTimeout: cfg.Redis.DialTimeout,
+		ReadTimeout: cfg.Redis.ReadTimeout,
+	})
+	attempts := lockout.NewFallbackStore(
+		lockout.NewRedisStore(redisClient),
+		store.LoginAttempts(),
+	)
+	guard := lockout.NewGuard(attempts, cfg.Lockout.Threshold, cfg.Lockout.Base, cfg.Lockout.Max, cfg.Lockout.Window)
+
+	var sender mail.Sender = mail.NewLogSender(slog.Default())
+	if cfg.Mail.SMTPAddr != "" {
+		sender = mail.NewSMTPSender(cfg.Mail.SMTPAddr, cfg.Mail.From, cfg.Mail.Username, cfg.Mail.Password)
+	} else if cfg.Mail.File != "" {
+		sender = mail.NewFileSender(cfg.Mail.File)
+	}
+	outbox := mail.NewOutbox(store, sender, cfg.Mail.PollInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		outbox.Run(ctx)
+	}()
+
+	passwords := password.NewChain(
+		password.NewArgon2id(password.DefaultArgon2idParams),
+		password.NewBcrypt(bcrypt.DefaultCost),
+	)
 
-	s := grpc.NewServer()
+	policy := password.NewPolicy(cfg.Password.MinLength, cfg.Password.MaxLength)
+	if err := policy.LoadBanned(cfg.Password.BannedFile); err != nil {
+		slog.Warn("cannot load banned passwords", "path", cfg.Password.BannedFile, "error", err)
+	}
+
+	// Events are always stored; publishing them is best effort.
+	var publisher audit.Publisher
+	nc, err := nats.Connect(cfg.NatsURL, nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1))
+	if err != nil {
+		slog.Warn("cannot connect to nats, audit events will not be published", "error", err)
+	} else {
+		publisher = nc
+	}
+	recorder := audit.NewRecorder(store, publisher)
+
+	g := grpcserver.New(store, keyring, guard, passwords, policy, oidcProviders(cfg.OIDCProviders), recorder, cfg.PublicURL)
+
+	clientIPs, err := cfg.ClientIPs()
+	if err != nil {
+		log.Fatalf("trusted_proxies: %v", err)
+	}
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor, clientIPs.UnaryServerInterceptor))
 	authv1.RegisterAuthServer(s, g)
 
-	log.Printf("gRPC server listening at %v", lis.Addr())
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	go func() {
+		log.Printf("gRPC server listening at %v", lis.Addr())
+		if err := s.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	gracefulStop(s, cfg.ShutdownTimeout)
+	workers.Wait()
+
+	if nc != nil {
+		// Flush audit events that are still buffered before closing.
+		if err := nc.FlushTimeout(cfg.ShutdownTimeout); err != nil {
+			slog.Warn("cannot flush nats connection", "error", err)
+		}
+		nc.Close()
+	}
+	if err := redisClient.Close(); err != nil {
+		slog.Warn("cannot close redis client", "error", err)
+	}
+	if err := store.Close(); err != nil {
+		slog.Error("cannot close storage", "error", err)
+	}
+	slog.Info("stopped")
+}
+
+// gracefulStop lets in-flight RPCs finish and forces the remaining ones
+// closed once timeout has passed.
+func gracefulStop(s *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("graceful stop timed out, closing remaining connections")
+		s.Stop()
+	}
+}
+
+func oidcProviders(cfgs []configs.OIDCProvider) []*oidc.Provider {
+	providers := make([]*oidc.Provider, 0, len(cfgs))
+	for _, c := range cfgs {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         c.Name,
+			Issuer:       c.Issuer,
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			RedirectURL:  c.RedirectURL,
+		}))
+		slog.Info("configured oidc provider", "name", c.Name)
 	}
+	return providers
 }
@@ -0,0 +1,46 @@
+// Command mock-oidc is a minimal OpenID Connect provider for local
+// development and manual testing of the OIDC login flow. It signs in
+// whoever types an email address into its login form.
+//
+// Configuration (environment):
+//
+//	MOCK_OIDC_ADDR           listen address (default :9000)
+//	MOCK_OIDC_ISSUER         issuer and base URL used by auth (default http://localhost:9000)
+//	MOCK_OIDC_PUBLIC_URL     base URL the browser uses, when it differs from the issuer
+//	MOCK_OIDC_CLIENT_ID      accepted client id (default comics-store)
+//	MOCK_OIDC_CLIENT_SECRET  accepted client secret (default secret)
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/barcek2281/comics-store/auth/internal/oidc/oidctest"
+)
+
+func main() {
+	issuer := env("MOCK_OIDC_ISSUER", "http://localhost:9000")
+	p, err := oidctest.New(
+		issuer,
+		env("MOCK_OIDC_PUBLIC_URL", issuer),
+		env("MOCK_OIDC_CLIENT_ID", "comics-store"),
+		env("MOCK_OIDC_CLIENT_SECRET", "secret"),
+	)
+	if err != nil {
+		log.Fatalf("cannot create provider: %v", err)
+	}
+
+	addr := env("MOCK_OIDC_ADDR", ":9000")
+	log.Printf("mock-oidc listening at %s, issuer %s", addr, issuer)
+	if err := http.ListenAndServe(addr, p); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func env(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -5,18 +5,43 @@ go 1.23.0
 toolchain go1.23.8
 
 require (
-	github.com/barcek2281/proto v0.0.0-20250412082746-8e5f3c47245f
+	github.com/barcek2281/comics-store/pkg v0.0.0-00010101000000-000000000000
+	github.com/barcek2281/proto v0.0.0-00010101000000-000000000000
+	github.com/go-redis/redis/v8 v8.11.5
+	github.com/ilyakaznacheev/cleanenv v1.5.0
 	github.com/mattn/go-sqlite3 v1.14.27
+	github.com/nats-io/nats.go v1.41.2
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f
 	google.golang.org/grpc v1.71.1
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/crypto v0.37.0
+require (
+	github.com/alicebob/miniredis/v2 v2.39.0
+	golang.org/x/crypto v0.37.0
+)
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/nats-io/nkeys v0.4.11 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
 	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
 )
+
+require (
+	github.com/BurntSushi/toml v1.5.0 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	olympos.io/encoding/edn v0.0.0-20201019073823-d3554ca0b0a3 // indirect
+)
+
+replace github.com/barcek2281/proto => ../proto
+
+replace github.com/barcek2281/comics-store/pkg => ../pkg
@@ -0,0 +1,61 @@
+// Package audit records security events of the auth service in an
+// append-only table and publishes them for other consumers.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+)
+
+// Subject is the NATS subject events are published on.
+const Subject = "auth.events"
+
+type Store interface {
+	SaveAuditEvent(ctx context.Context, event model.AuditEvent) (int64, error)
+}
+
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Recorder persists events and then publishes them. Recording never fails
+// the operation being audited: errors are logged instead.
+type Recorder struct {
+	store     Store
+	publisher Publisher
+}
+
+// NewRecorder builds a Recorder. publisher may be nil, in which case events
+// are only stored.
+func NewRecorder(store Store, publisher Publisher) *Recorder {
+	return &Recorder{store: store, publisher: publisher}
+}
+
+func (r *Recorder) Record(ctx context.Context, event model.AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	id, err := r.store.SaveAuditEvent(ctx, event)
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot store audit event", "type", event.Type, "user_id", event.UserID, "error", err)
+		return
+	}
+	event.ID = id
+
+	if r.publisher == nil {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot encode audit event", "id", id, "error", err)
+		return
+	}
+	if err := r.publisher.Publish(Subject, data); err != nil {
+		slog.WarnContext(ctx, "cannot publish audit event", "id", id, "error", err)
+	}
+}
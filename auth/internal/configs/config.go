@@ -0,0 +1,208 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/barcek2281/comics-store/pkg/clientip"
+	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Config is read from a YAML file; every field can be overridden by the
+// environment variable named in its env tag.
+type Config struct {
+	Port            int            `yaml:"port" env:"PORT" env-default:"50051"`
+	LogLevel        string         `yaml:"log_level" env:"LOG_LEVEL" env-default:"info"`
+	ShutdownTimeout time.Duration  `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
+	StoragePath     string         `yaml:"storage_path" env:"STORAGE_PATH" env-default:"./storage/user.db"`
+	PublicURL       string         `yaml:"public_url" env:"PUBLIC_URL" env-default:"http://localhost:8080"`
+	NatsURL         string         `yaml:"nats_url" env:"NATS_URL" env-default:"nats://nats:4222"`
+	TrustedProxies  []string       `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	Redis           Redis          `yaml:"redis"`
+	Keys            Keys           `yaml:"keys"`
+	Lockout         Lockout        `yaml:"lockout"`
+	Mail            Mail           `yaml:"mail"`
+	Password        Password       `yaml:"password"`
+	OIDCProviders   []OIDCProvider `yaml:"oidc_providers"`
+}
+
+type Redis struct {
+	Addr        string        `yaml:"addr" env:"REDIS_ADDR" env-default:"redis:6379"`
+	Password    string        `yaml:"password" env:"REDIS_PASSWORD"`
+	DialTimeout time.Duration `yaml:"dial_timeout" env:"REDIS_DIAL_TIMEOUT" env-default:"300ms"`
+	ReadTimeout time.Duration `yaml:"read_timeout" env:"REDIS_READ_TIMEOUT" env-default:"300ms"`
+}
+
+// Keys controls signing key rotation.
+type Keys struct {
+	RotateEvery time.Duration `yaml:"rotate_every" env:"KEYS_ROTATE_EVERY" env-default:"24h"`
+	RetainFor   time.Duration `yaml:"retain_for" env:"KEYS_RETAIN_FOR" env-default:"24h"`
+}
+
+type Lockout struct {
+	Threshold int           `yaml:"threshold" env:"LOCKOUT_THRESHOLD" env-default:"5"`
+	Base      time.Duration `yaml:"base" env:"LOCKOUT_BASE" env-default:"30s"`
+	Max       time.Duration `yaml:"max" env:"LOCKOUT_MAX" env-default:"1h"`
+	Window    time.Duration `yaml:"window" env:"LOCKOUT_WINDOW" env-default:"15m"`
+}
+
+// Mail goes through SMTP when SMTPAddr is set, to File when that is set,
+// and to the log otherwise.
+type Mail struct {
+	SMTPAddr     string        `yaml:"smtp_addr" env:"SMTP_ADDR"`
+	From         string        `yaml:"from" env:"SMTP_FROM"`
+	Username     string        `yaml:"username" env:"SMTP_USERNAME"`
+	Password     string        `yaml:"password" env:"SMTP_PASSWORD"`
+	File         string        `yaml:"file" env:"MAIL_FILE"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"MAIL_POLL_INTERVAL" env-default:"10s"`
+}
+
+type Password struct {
+	MinLength  int    `yaml:"min_length" env:"PASSWORD_MIN_LENGTH" env-default:"8"`
+	MaxLength  int    `yaml:"max_length" env:"PASSWORD_MAX_LENGTH" env-default:"128"`
+	BannedFile string `yaml:"banned_file" env:"PASSWORD_BANNED_FILE" env-default:"./config/banned_passwords.txt"`
+}
+
+// OIDCProvider configures one external login provider. The client secret
+// can be kept out of the file with OIDC_<NAME>_CLIENT_SECRET.
+type OIDCProvider struct {
+	Name         string `yaml:"name"`
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	RedirectURL  string `yaml:"redirect_url"`
+}
+
+func MustLoad(configPath string) *Config {
+	var config Config
+
+	if err := cleanenv.ReadConfig(configPath, &config); err != nil {
+		panic(err)
+	}
+
+	for i := range config.OIDCProviders {
+		p := &config.OIDCProviders[i]
+		if secret := os.Getenv("OIDC_" + strings.ToUpper(p.Name) + "_CLIENT_SECRET"); secret != "" {
+			p.ClientSecret = secret
+		}
+		if p.RedirectURL == "" {
+			p.RedirectURL = strings.TrimSuffix(config.PublicURL, "/") + "/auth/oidc/" + p.Name + "/callback"
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		panic(fmt.Errorf("invalid config %s: %w", configPath, err))
+	}
+
+	return &config
+}
+
+// Validate reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	if _, err := c.Level(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.StoragePath == "" {
+		errs = append(errs, errors.New("storage_path is required"))
+	}
+	if u, err := url.Parse(c.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("public_url %q is not an absolute URL", c.PublicURL))
+	}
+	if c.NatsURL == "" {
+		errs = append(errs, errors.New("nats_url is required"))
+	}
+	if _, err := c.ClientIPs(); err != nil {
+		errs = append(errs, fmt.Errorf("trusted_proxies: %w", err))
+	}
+	if c.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required"))
+	}
+	if c.Keys.RotateEvery <= 0 || c.Keys.RetainFor <= 0 {
+		errs = append(errs, errors.New("keys.rotate_every and keys.retain_for must be positive"))
+	}
+	if c.Lockout.Threshold < 1 {
+		errs = append(errs, errors.New("lockout.threshold must be at least 1"))
+	}
+	if c.Lockout.Base <= 0 || c.Lockout.Max < c.Lockout.Base || c.Lockout.Window <= 0 {
+		errs = append(errs, errors.New("lockout durations must be positive and max must not be below base"))
+	}
+	if c.Mail.SMTPAddr != "" && c.Mail.From == "" {
+		errs = append(errs, errors.New("mail.from is required with mail.smtp_addr"))
+	}
+	if c.Mail.PollInterval <= 0 {
+		errs = append(errs, errors.New("mail.poll_interval must be positive"))
+	}
+	if c.Password.MinLength < 1 || c.Password.MaxLength < c.Password.MinLength {
+		errs = append(errs, errors.New("password.min_length must be at least 1 and not above password.max_length"))
+	}
+
+	seen := make(map[string]bool, len(c.OIDCProviders))
+	for i, p := range c.OIDCProviders {
+		if p.Name == "" || p.Issuer == "" || p.ClientID == "" {
+			errs = append(errs, fmt.Errorf("oidc_providers[%d]: name, issuer and client_id are required", i))
+		}
+		if seen[p.Name] {
+			errs = append(errs, fmt.Errorf("oidc_providers[%d]: duplicate name %q", i, p.Name))
+		}
+		seen[p.Name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// ClientIPs builds the resolver that trusts forwarded client addresses
+// only from TrustedProxies.
+func (c *Config) ClientIPs() (*clientip.Resolver, error) {
+	return clientip.New(c.TrustedProxies)
+}
+
+// Level parses LogLevel.
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("log_level: %w", err)
+	}
+	return level, nil
+}
+
+// Print writes the config as YAML with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	c.Redis.Password = redact(c.Redis.Password)
+	c.Mail.Password = redact(c.Mail.Password)
+
+	providers := make([]OIDCProvider, len(c.OIDCProviders))
+	for i, p := range c.OIDCProviders {
+		p.ClientSecret = redact(p.ClientSecret)
+		providers[i] = p
+	}
+	c.OIDCProviders = providers
+
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
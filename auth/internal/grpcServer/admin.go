@@ -0,0 +1,179 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ListUsers pages through accounts, optionally filtered by a substring of
+// the email. Admin only.
+func (g *GRPCserver) ListUsers(ctx context.Context, in *authv1.ListUsersRequest) (*authv1.ListUsersResponse, error) {
+	if _, err := g.authorize(ctx, model.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	page, size := int(in.Page), int(in.PageSize)
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = defaultPageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+
+	users, total, err := g.store.ListUsers(ctx, strings.TrimSpace(in.Query), size, (page-1)*size)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "list users")
+	}
+
+	profiles := make([]*authv1.UserProfile, 0, len(users))
+	for _, user := range users {
+		profiles = append(profiles, toProfile(user))
+	}
+
+	return &authv1.ListUsersResponse{
+		Users:    profiles,
+		Total:    int64(total),
+		Page:     int32(page),
+		PageSize: int32(size),
+	}, nil
+}
+
+func (g *GRPCserver) GetUser(ctx context.Context, in *authv1.GetUserRequest) (*authv1.UserProfile, error) {
+	if _, err := g.authorize(ctx, model.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.UserByID(ctx, in.UserId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return toProfile(user), nil
+}
+
+// SetRoles replaces the roles of a user. The new roles only show up in
+// tokens issued afterwards, so existing tokens are revoked.
+func (g *GRPCserver) SetRoles(ctx context.Context, in *authv1.SetRolesRequest) (*authv1.UserProfile, error) {
+	c, err := g.authorize(ctx, model.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := validRoles(in.Roles)
+	if err != nil {
+		return nil, err
+	}
+	if in.UserId == c.UserID && !contains(roles, model.RoleAdmin) {
+		return nil, status.Error(codes.FailedPrecondition, "cannot remove your own admin role")
+	}
+
+	user, err := g.store.UserByID(ctx, in.UserId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	if err := g.store.SetRoles(ctx, user.ID, roles); err != nil {
+		return nil, status.Error(codes.Internal, "set roles")
+	}
+	if err := g.store.RevokeUserTokens(ctx, user.ID); err != nil {
+		return nil, status.Error(codes.Internal, "revoke tokens")
+	}
+	user.Roles = roles
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{
+		Type:    model.EventRoleChange,
+		UserID:  user.ID,
+		ActorID: c.UserID,
+		Email:   user.Email,
+		Success: true,
+		Detail:  strings.Join(roles, ","),
+	})
+
+	return toProfile(user), nil
+}
+
+// DisableUser blocks an account from logging in and revokes every token it
+// holds. Admin only.
+func (g *GRPCserver) DisableUser(ctx context.Context, in *authv1.DisableUserRequest) (*authv1.UserProfile, error) {
+	c, err := g.authorize(ctx, model.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if in.UserId == c.UserID {
+		return nil, status.Error(codes.FailedPrecondition, "cannot disable your own account")
+	}
+
+	return g.setDisabled(ctx, c.UserID, in.UserId, true)
+}
+
+func (g *GRPCserver) EnableUser(ctx context.Context, in *authv1.EnableUserRequest) (*authv1.UserProfile, error) {
+	c, err := g.authorize(ctx, model.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.setDisabled(ctx, c.UserID, in.UserId, false)
+}
+
+func (g *GRPCserver) setDisabled(ctx context.Context, actorID, id int64, disabled bool) (*authv1.UserProfile, error) {
+	user, err := g.store.UserByID(ctx, id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	if err := g.store.SetDisabled(ctx, user.ID, disabled); err != nil {
+		return nil, status.Error(codes.Internal, "update user")
+	}
+	if disabled {
+		if err := g.store.RevokeUserTokens(ctx, user.ID); err != nil {
+			return nil, status.Error(codes.Internal, "revoke tokens")
+		}
+	}
+	user.Disabled = disabled
+
+	event := model.EventUserEnabled
+	if disabled {
+		event = model.EventUserDisabled
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: event, UserID: user.ID, ActorID: actorID, Email: user.Email, Success: true})
+
+	return toProfile(user), nil
+}
+
+func validRoles(roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one role is required")
+	}
+
+	out := make([]string, 0, len(roles))
+	for _, role := range roles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if !contains(model.Roles, role) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown role %q", role)
+		}
+		if !contains(out, role) {
+			out = append(out, role)
+		}
+	}
+	return out, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
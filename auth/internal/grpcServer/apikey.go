@@ -0,0 +1,170 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/apikey"
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateAPIKey issues a key for a service account, creating the account on
+// first use. The full key is only ever returned here. Admin only.
+func (g *GRPCserver) CreateAPIKey(ctx context.Context, in *authv1.CreateAPIKeyRequest) (*authv1.CreateAPIKeyResponse, error) {
+	c, err := g.authorize(ctx, model.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(in.ServiceAccount)
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "service_account is required")
+	}
+	scopes, err := validScopes(in.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := g.store.EnsureServiceAccount(ctx, name)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "service account")
+	}
+
+	key, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "generate api key")
+	}
+
+	stored := model.APIKey{
+		ServiceAccountID: account.ID,
+		ServiceAccount:   account.Name,
+		Prefix:           prefix,
+		SecretHash:       hash,
+		Scopes:           scopes,
+		CreatedAt:        time.Now(),
+	}
+	stored.ID, err = g.store.SaveAPIKey(ctx, stored)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "save api key")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{
+		Type:    model.EventAPIKeyCreated,
+		ActorID: c.UserID,
+		Success: true,
+		Detail:  account.Name + ":" + prefix,
+	})
+
+	return &authv1.CreateAPIKeyResponse{Key: key, ApiKey: toAPIKey(stored)}, nil
+}
+
+func (g *GRPCserver) ListAPIKeys(ctx context.Context, in *authv1.ListAPIKeysRequest) (*authv1.ListAPIKeysResponse, error) {
+	if _, err := g.authorize(ctx, model.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	keys, err := g.store.APIKeys(ctx, strings.TrimSpace(in.ServiceAccount))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "list api keys")
+	}
+
+	out := make([]*authv1.APIKey, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, toAPIKey(k))
+	}
+	return &authv1.ListAPIKeysResponse{Keys: out}, nil
+}
+
+func (g *GRPCserver) RevokeAPIKey(ctx context.Context, in *authv1.RevokeAPIKeyRequest) (*authv1.RevokeAPIKeyResponse, error) {
+	c, err := g.authorize(ctx, model.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.store.RevokeAPIKey(ctx, in.Id)
+	if errors.Is(err, storage.ErrTokenNotFound) {
+		return nil, status.Error(codes.NotFound, "api key not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "revoke api key")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventAPIKeyRevoked, ActorID: c.UserID, Success: true, Detail: fmt.Sprintf("key:%d", in.Id)})
+
+	return &authv1.RevokeAPIKeyResponse{Success: true}, nil
+}
+
+// IntrospectAPIKey resolves an API key to its service account and scopes.
+// Like Introspect, unknown or revoked keys are not an error.
+func (g *GRPCserver) IntrospectAPIKey(ctx context.Context, in *authv1.IntrospectAPIKeyRequest) (*authv1.IntrospectAPIKeyResponse, error) {
+	prefix, secret, ok := apikey.Split(in.Key)
+	if !ok {
+		return &authv1.IntrospectAPIKeyResponse{Active: false}, nil
+	}
+
+	key, err := g.store.APIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, storage.ErrTokenNotFound) {
+		return &authv1.IntrospectAPIKeyResponse{Active: false}, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "api key lookup")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(jwt.HashToken(secret))) != 1 || key.Revoked {
+		return &authv1.IntrospectAPIKeyResponse{Active: false}, nil
+	}
+
+	if err := g.store.TouchAPIKey(ctx, key.ID); err != nil {
+		slog.WarnContext(ctx, "cannot record api key use", "key_id", key.ID, "error", err)
+	}
+
+	return &authv1.IntrospectAPIKeyResponse{
+		Active:           true,
+		KeyId:            key.ID,
+		ServiceAccountId: key.ServiceAccountID,
+		ServiceAccount:   key.ServiceAccount,
+		Roles:            []string{model.RoleService},
+		Scopes:           key.Scopes,
+	}, nil
+}
+
+func validScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one scope is required")
+	}
+
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !contains(model.Scopes, scope) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown scope %q", scope)
+		}
+		if !contains(out, scope) {
+			out = append(out, scope)
+		}
+	}
+	return out, nil
+}
+
+func toAPIKey(k model.APIKey) *authv1.APIKey {
+	out := &authv1.APIKey{
+		Id:             k.ID,
+		ServiceAccount: k.ServiceAccount,
+		Prefix:         k.Prefix,
+		Scopes:         k.Scopes,
+		CreatedAt:      k.CreatedAt.Format(time.RFC3339),
+		Revoked:        k.Revoked,
+	}
+	if !k.LastUsedAt.IsZero() {
+		out.LastUsedAt = k.LastUsedAt.Format(time.RFC3339)
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/pkg/clientip"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// caller is the identity behind the bearer token of an incoming call.
+type caller struct {
+	UserID    int64
+	Email     string
+	Roles     []string
+	Jti       string
+	SessionID string
+}
+
+// authorize resolves the bearer token in the "authorization" metadata and,
+// when roles are given, requires the caller to hold one of them.
+func (g *GRPCserver) authorize(ctx context.Context, roles ...string) (caller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return caller{}, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	claims, err := jwt.Parse(g.keyring, strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return caller{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	c := caller{Roles: stringSlice(claims["roles"])}
+	c.Jti, _ = claims["jti"].(string)
+	c.SessionID, _ = claims["sid"].(string)
+	c.Email, _ = claims["email"].(string)
+	if uid, ok := claims["uid"].(float64); ok {
+		c.UserID = int64(uid)
+	}
+
+	revoked, err := g.tokenRevoked(ctx, claims)
+	if err != nil {
+		return caller{}, status.Error(codes.Internal, "revocation lookup")
+	}
+	if revoked {
+		return caller{}, status.Error(codes.Unauthenticated, "token revoked")
+	}
+
+	if len(roles) == 0 {
+		return c, nil
+	}
+	for _, have := range c.Roles {
+		for _, want := range roles {
+			if have == want {
+				return c, nil
+			}
+		}
+	}
+	return caller{}, status.Error(codes.PermissionDenied, "missing role")
+}
+
+// clientIP returns the end client's address as resolved by the
+// clientip interceptor from the peer and, behind a trusted proxy, the
+// forwarded metadata.
+func clientIP(ctx context.Context) string {
+	return clientip.FromContext(ctx)
+}
+
+// userAgent returns the user agent of the end client as forwarded by the
+// gateway, falling back to the one of the gRPC client.
+func userAgent(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("x-user-agent"); len(values) > 0 {
+		return values[0]
+	}
+	if values := md.Get("user-agent"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -2,69 +2,345 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/barcek2281/comics-store/auth/internal/audit"
 	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/lib/password"
+	"github.com/barcek2281/comics-store/auth/internal/lockout"
 	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/oidc"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
 	sqlite1488 "github.com/barcek2281/comics-store/auth/internal/storage/sqlite3"
+	"github.com/barcek2281/comics-store/pkg/validate"
 	authv1 "github.com/barcek2281/proto/gen/go/auth"
-	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var errAccountDisabled = status.Error(codes.PermissionDenied, "account is disabled")
+
+const (
+	accessTokenTTL  = time.Minute * 15
+	refreshTokenTTL = time.Hour * 24 * 30
 )
 
 type GRPCserver struct {
-	store *sqlite1488.Storage
+	store     *sqlite1488.Storage
+	keyring   *jwt.Keyring
+	guard     *lockout.Guard
+	passwords *password.Chain
+	policy    *password.Policy
+	providers map[string]*oidc.Provider
+	audit     *audit.Recorder
+	publicURL string
 	authv1.UnimplementedAuthServer
 }
 
-func New(store *sqlite1488.Storage) *GRPCserver {
+func New(store *sqlite1488.Storage, keyring *jwt.Keyring, guard *lockout.Guard, passwords *password.Chain, policy *password.Policy, providers []*oidc.Provider, recorder *audit.Recorder, publicURL string) *GRPCserver {
+	byName := make(map[string]*oidc.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+
 	return &GRPCserver{
-		store: store,
+		store:     store,
+		keyring:   keyring,
+		guard:     guard,
+		passwords: passwords,
+		policy:    policy,
+		providers: byName,
+		audit:     recorder,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
 	}
 }
 
 func (g *GRPCserver) Register(ctx context.Context, in *authv1.RegisterRequest) (*authv1.RegisterResponse, error) {
+	if err := validate.Struct(credentialsInput{Email: in.Email, Password: in.Password}); err != nil {
+		return nil, err
+	}
+	if err := checkPassword(g.policy, "password", in.Password, in.Email); err != nil {
+		return nil, err
+	}
 
-	HashPassword, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+	HashPassword, err := g.passwords.Hash(in.Password)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "hash password")
+	}
 
 	user := model.User{
 		Email:    in.Email,
-		Password: string(HashPassword),
+		Password: HashPassword,
+		Roles:    []string{model.RoleCustomer},
 	}
+	dev := deviceFrom(ctx)
 	id, err := g.store.Save(user)
+	if errors.Is(err, storage.ErrUserExists) {
+		g.record(ctx, dev, model.AuditEvent{Type: model.EventRegister, Email: in.Email, Success: false, Detail: "email_in_use"})
+		return nil, status.Error(codes.AlreadyExists, "email is used")
+	}
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "email is used")
+		return nil, status.Error(codes.Internal, "save user")
 	}
 	user.ID = id
+	g.record(ctx, dev, model.AuditEvent{Type: model.EventRegister, UserID: id, Email: user.Email, Success: true})
 
-	token, err := jwt.NewToken("secret", user, time.Hour*24)
+	if err := g.sendVerification(ctx, user); err != nil {
+		return nil, status.Error(codes.Internal, "queue verification mail")
+	}
 
+	token, refreshToken, err := g.startSession(ctx, user, dev, false)
 	if err != nil {
 		return nil, status.Error(codes.Internal, "jwt issue")
 
 	}
 
-	return &authv1.RegisterResponse{Token: token}, nil
+	return &authv1.RegisterResponse{Token: token, RefreshToken: refreshToken}, nil
 }
 
 func (g *GRPCserver) Login(ctx context.Context, in *authv1.LoginRequest) (*authv1.LoginResponse, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	if err := validate.Struct(credentialsInput{Email: in.Email, Password: in.Password}); err != nil {
+		return nil, err
+	}
+
+	dev := deviceFrom(ctx)
+	keys := []string{lockout.EmailKey(in.Email), lockout.IPKey(dev.IP)}
+
+	if wait := g.guard.Check(ctx, keys...); wait > 0 {
+		g.loginFailed(ctx, dev, 0, in.Email, "locked_out")
+		return nil, tooManyAttempts(wait)
+	}
 
 	user, err := g.store.User(ctx, in.Email)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		return nil, status.Error(codes.Internal, "find user")
+	}
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "email or password not found")
+		g.loginFailed(ctx, dev, 0, in.Email, "unknown_email")
+		if wait := g.guard.Fail(ctx, keys...); wait > 0 {
+			return nil, tooManyAttempts(wait)
+		}
+		return nil, status.Error(codes.Unauthenticated, "email or password not found")
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(in.Password))
+	ok, rehash, err := g.passwords.Verify(user.Password, in.Password)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "email or password not found")
+		slog.ErrorContext(ctx, "cannot verify password", "user_id", user.ID, "error", err)
+	}
+	if !ok {
+		g.loginFailed(ctx, dev, user.ID, user.Email, "wrong_password")
+		if wait := g.guard.Fail(ctx, keys...); wait > 0 {
+			return nil, tooManyAttempts(wait)
+		}
+		return nil, status.Error(codes.Unauthenticated, "email or password not found")
+	}
+	// Only the account's counter is cleared: resetting the address too
+	// would let one valid login wipe the failures of a guessing client.
+	g.guard.Reset(ctx, lockout.EmailKey(in.Email))
+
+	if rehash {
+		g.rehashPassword(ctx, user.ID, in.Password)
+	}
+
+	if user.Disabled {
+		g.loginFailed(ctx, dev, user.ID, user.Email, "disabled")
+		return nil, errAccountDisabled
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := jwt.NewPurposeToken(g.keyring, jwt.PurposeTOTPChallenge, user, totpChallengeTTL)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "jwt issue")
+		}
+		return &authv1.LoginResponse{TwoFactorRequired: true, ChallengeToken: challenge}, nil
 	}
-	token, err := jwt.NewToken("secret", user, time.Hour*24)
+
+	token, refreshToken, err := g.startSession(ctx, user, dev, false)
 	if err != nil {
 		return nil, status.Error(codes.Internal, "jwt issue")
 	}
+	g.loginSucceeded(ctx, dev, user, "password")
+
+	return &authv1.LoginResponse{Token: token, RefreshToken: refreshToken}, nil
+}
+
+// UnlockAccount clears the failed login counter of an email. Admin only.
+func (g *GRPCserver) UnlockAccount(ctx context.Context, in *authv1.UnlockAccountRequest) (*authv1.UnlockAccountResponse, error) {
+	if _, err := g.authorize(ctx, model.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(emailInput{Email: in.Email}); err != nil {
+		return nil, err
+	}
+
+	g.guard.Reset(ctx, lockout.EmailKey(in.Email))
+	return &authv1.UnlockAccountResponse{Success: true}, nil
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already
+// rotated or revoked is treated as theft and revokes the whole family.
+func (g *GRPCserver) Refresh(ctx context.Context, in *authv1.RefreshRequest) (*authv1.RefreshResponse, error) {
+	stored, err := g.store.RefreshToken(ctx, jwt.HashToken(in.RefreshToken))
+	if errors.Is(err, storage.ErrTokenNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "refresh token lookup")
+	}
+
+	if stored.Used || stored.Revoked {
+		if err := g.store.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, status.Error(codes.Internal, "revoke token family")
+		}
+		g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventTokenRevoked, UserID: stored.UserID, Success: true, Detail: "refresh_token_reuse"})
+		return nil, status.Error(codes.Unauthenticated, "refresh token reuse detected")
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, status.Error(codes.Unauthenticated, "refresh token expired")
+	}
+
+	err = g.store.UseRefreshToken(ctx, stored.ID)
+	if errors.Is(err, storage.ErrTokenUsed) {
+		if err := g.store.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, status.Error(codes.Internal, "revoke token family")
+		}
+		g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventTokenRevoked, UserID: stored.UserID, Success: true, Detail: "refresh_token_reuse"})
+		return nil, status.Error(codes.Unauthenticated, "refresh token reuse detected")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "refresh token update")
+	}
+
+	user, err := g.store.UserByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "user not found")
+	}
+	if user.Disabled {
+		return nil, errAccountDisabled
+	}
 
-	return &authv1.LoginResponse{Token: token}, nil
+	if err := g.store.TouchSession(ctx, stored.FamilyID, clientIP(ctx)); err != nil {
+		return nil, status.Error(codes.Internal, "session update")
+	}
+
+	token, refreshToken, err := g.issueTokens(ctx, user, stored.FamilyID, stored.MFA)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "jwt issue")
+	}
+
+	return &authv1.RefreshResponse{Token: token, RefreshToken: refreshToken}, nil
+}
+
+func (g *GRPCserver) Logout(ctx context.Context, in *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	stored, err := g.store.RefreshToken(ctx, jwt.HashToken(in.RefreshToken))
+	if errors.Is(err, storage.ErrTokenNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "refresh token lookup")
+	}
+
+	if err := g.store.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+		return nil, status.Error(codes.Internal, "revoke token family")
+	}
+	if err := g.revokeAccessToken(ctx, in.Token); err != nil {
+		return nil, status.Error(codes.Internal, "revoke access token")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventTokenRevoked, UserID: stored.UserID, Success: true, Detail: "logout"})
+
+	return &authv1.LogoutResponse{Success: true}, nil
+}
+
+func (g *GRPCserver) GetJWKS(ctx context.Context, in *authv1.GetJWKSRequest) (*authv1.GetJWKSResponse, error) {
+	var keys []*authv1.JWK
+	for _, k := range g.keyring.PublicKeys() {
+		keys = append(keys, &authv1.JWK{
+			Kid: k.Kid,
+			Kty: k.Kty,
+			Crv: k.Crv,
+			X:   k.X,
+			Alg: k.Alg,
+			Use: k.Use,
+		})
+	}
+	return &authv1.GetJWKSResponse{Keys: keys}, nil
+}
+
+// device describes the client a session was started from.
+type device struct {
+	UserAgent string
+	IP        string
+}
+
+func deviceFrom(ctx context.Context) device {
+	return device{UserAgent: userAgent(ctx), IP: clientIP(ctx)}
+}
+
+// startSession records a new session for user on dev and issues its first
+// pair of tokens. The session id doubles as the refresh token family. mfa
+// must only be set once the user passed the second factor.
+func (g *GRPCserver) startSession(ctx context.Context, user model.User, dev device, mfa bool) (string, string, error) {
+	id, err := jwt.NewID()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	err = g.store.SaveSession(ctx, model.Session{
+		ID:         id,
+		UserID:     user.ID,
+		UserAgent:  dev.UserAgent,
+		IP:         dev.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return g.issueTokens(ctx, user, id, mfa)
+}
+
+// issueTokens mints an access token and a refresh token for user within
+// the session familyID, which was opened with the second factor if mfa is
+// set.
+func (g *GRPCserver) issueTokens(ctx context.Context, user model.User, familyID string, mfa bool) (string, string, error) {
+	token, err := jwt.NewToken(g.keyring, user, familyID, mfa, accessTokenTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, hash, err := jwt.NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	err = g.store.SaveRefreshToken(ctx, model.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		FamilyID:  familyID,
+		MFA:       mfa,
+		ExpiresAt: now.Add(refreshTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return token, refreshToken, nil
+}
+
+func tooManyAttempts(wait time.Duration) error {
+	st := status.New(codes.ResourceExhausted, "too many failed login attempts")
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
 }
@@ -0,0 +1,271 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/audit"
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/lib/password"
+	"github.com/barcek2281/comics-store/auth/internal/lockout"
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	sqlite1488 "github.com/barcek2281/comics-store/auth/internal/storage/sqlite3"
+	"github.com/barcek2281/comics-store/auth/migrations"
+	"github.com/barcek2281/comics-store/pkg/migrator"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testPassword = "correct horse battery staple"
+
+// newTestServer returns a server on a fresh database. Failed logins lock
+// an account out after three attempts.
+func newTestServer(t *testing.T) *GRPCserver {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.db")
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.New(db, migrations.FS).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := sqlite1488.NewStorage(path + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	keyring := jwt.NewKeyring(store, time.Hour, time.Hour)
+	if err := keyring.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	guard := lockout.NewGuard(store.LoginAttempts(), 3, time.Minute, time.Hour, time.Hour)
+	passwords := password.NewChain(password.NewBcrypt(bcrypt.MinCost))
+
+	return New(store, keyring, guard, passwords, password.NewPolicy(8, 128), nil, audit.NewRecorder(store, nil), "http://gateway.test")
+}
+
+// newUser stores a customer with testPassword and returns it.
+func newUser(t *testing.T, g *GRPCserver, email string) model.User {
+	t.Helper()
+	hash, err := g.passwords.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := model.User{Email: email, Password: hash, Roles: []string{model.RoleCustomer}}
+	if user.ID, err = g.store.Save(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// login signs user in with testPassword and returns its tokens.
+func login(t *testing.T, g *GRPCserver, email string) *authv1.LoginResponse {
+	t.Helper()
+	res, err := g.Login(context.Background(), &authv1.LoginRequest{Email: email, Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login(%s) error = %v", email, err)
+	}
+	return res
+}
+
+// signedIn returns a context that carries token like the gateway sends it.
+func signedIn(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+var mailLink = regexp.MustCompile(`http://\S+`)
+
+// mailedToken returns the token of the link last mailed to recipient.
+func mailedToken(t *testing.T, g *GRPCserver, recipient string) string {
+	t.Helper()
+	msgs, err := g.store.PendingMail(context.Background(), 100, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Recipient != recipient {
+			continue
+		}
+		link, err := url.Parse(mailLink.FindString(msgs[i].Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return link.Query().Get("token")
+	}
+	t.Fatalf("no mail to %s", recipient)
+	return ""
+}
+
+func mfaClaim(t *testing.T, g *GRPCserver, token string) bool {
+	t.Helper()
+	claims, err := jwt.Parse(g.keyring, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa, _ := claims["mfa"].(bool)
+	return mfa
+}
+
+func enableTOTP(t *testing.T, g *GRPCserver, user model.User, recoveryCode string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := g.store.SetTOTPSecret(ctx, user.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.store.EnableTOTP(ctx, user.ID, []string{jwt.HashToken(normalizeRecoveryCode(recoveryCode))}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMFAClaimFollowsTheSession(t *testing.T) {
+	g := newTestServer(t)
+	ctx := context.Background()
+	user := newUser(t, g, "reader@example.com")
+
+	// A session opened before TOTP was enabled never gains the flag.
+	before := login(t, g, user.Email)
+	enableTOTP(t, g, user, "aaaa-bbbb")
+	refreshed, err := g.Refresh(ctx, &authv1.RefreshRequest{RefreshToken: before.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mfaClaim(t, g, before.Token) || mfaClaim(t, g, refreshed.Token) {
+		t.Error("password-only session has mfa set")
+	}
+
+	challenge := login(t, g, user.Email)
+	if !challenge.TwoFactorRequired {
+		t.Fatal("login of a TOTP user did not ask for the second factor")
+	}
+	verified, err := g.VerifySecondFactor(ctx, &authv1.VerifySecondFactorRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		RecoveryCode:   "aaaa-bbbb",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err = g.Refresh(ctx, &authv1.RefreshRequest{RefreshToken: verified.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mfaClaim(t, g, verified.Token) || !mfaClaim(t, g, refreshed.Token) {
+		t.Error("session opened with the second factor lost mfa")
+	}
+}
+
+func TestChangeEmailKeepsAddressUntilConfirmed(t *testing.T) {
+	g := newTestServer(t)
+	user := newUser(t, g, "reader@example.com")
+	ctx := signedIn(login(t, g, user.Email).Token)
+
+	_, err := g.ChangeEmail(ctx, &authv1.ChangeEmailRequest{NewEmail: "new@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := g.store.UserByID(ctx, user.ID); got.Email != user.Email {
+		t.Fatalf("email = %s before confirmation, want %s", got.Email, user.Email)
+	}
+
+	msgs, err := g.store.PendingMail(ctx, 100, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notified := false
+	for _, msg := range msgs {
+		notified = notified || msg.Recipient == user.Email
+	}
+	if !notified {
+		t.Error("old address was not told about the change")
+	}
+
+	token := mailedToken(t, g, "new@example.com")
+	if _, err := g.VerifyEmail(context.Background(), &authv1.VerifyEmailRequest{Token: token}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := g.store.UserByID(ctx, user.ID)
+	if got.Email != "new@example.com" || !got.EmailVerified {
+		t.Errorf("after confirmation email = %s verified = %v, want new@example.com verified", got.Email, got.EmailVerified)
+	}
+}
+
+func TestReauthenticationCountsTowardsLockout(t *testing.T) {
+	g := newTestServer(t)
+	user := newUser(t, g, "reader@example.com")
+	ctx := signedIn(login(t, g, user.Email).Token)
+
+	for i := 0; i < 2; i++ {
+		_, err := g.ChangeEmail(ctx, &authv1.ChangeEmailRequest{NewEmail: "new@example.com", Password: "wrong password"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("ChangeEmail() with a wrong password error = %v, want InvalidArgument", err)
+		}
+	}
+	_, err := g.DeleteAccount(ctx, &authv1.DeleteAccountRequest{Password: "wrong password"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("third wrong password error = %v, want ResourceExhausted", err)
+	}
+	_, err = g.DeleteAccount(ctx, &authv1.DeleteAccountRequest{Password: testPassword})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("DeleteAccount() while locked out error = %v, want ResourceExhausted", err)
+	}
+}
+
+func introspect(t *testing.T, g *GRPCserver, token string) bool {
+	t.Helper()
+	res, err := g.Introspect(context.Background(), &authv1.IntrospectRequest{Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Active
+}
+
+func TestIntrospectDisabledUser(t *testing.T) {
+	g := newTestServer(t)
+	user := newUser(t, g, "reader@example.com")
+	token := login(t, g, user.Email).Token
+
+	if !introspect(t, g, token) {
+		t.Fatal("fresh token is not active")
+	}
+	if err := g.store.SetDisabled(context.Background(), user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if introspect(t, g, token) {
+		t.Error("token of a disabled user is active")
+	}
+}
+
+func TestIntrospectRevocationInSameSecond(t *testing.T) {
+	g := newTestServer(t)
+	user := newUser(t, g, "reader@example.com")
+
+	// Wait for the start of a second, so the token and the revocation
+	// share it.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	before := login(t, g, user.Email).Token
+	time.Sleep(2 * time.Millisecond)
+	if err := g.store.RevokeUserTokens(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	after := login(t, g, user.Email).Token
+
+	if introspect(t, g, before) {
+		t.Error("token issued before the revocation in the same second is active")
+	}
+	if !introspect(t, g, after) {
+		t.Error("token issued after the revocation in the same second is not active")
+	}
+}
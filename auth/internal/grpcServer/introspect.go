@@ -0,0 +1,124 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	gojwt "github.com/golang-jwt/jwt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Introspect reports whether a token is currently usable and who it belongs
+// to, so other services can authenticate callers without the signing keys.
+// Invalid tokens are not an error: they come back with Active unset.
+func (g *GRPCserver) Introspect(ctx context.Context, in *authv1.IntrospectRequest) (*authv1.IntrospectResponse, error) {
+	claims, err := jwt.Parse(g.keyring, in.Token)
+	if err != nil {
+		return &authv1.IntrospectResponse{Active: false}, nil
+	}
+
+	revoked, err := g.tokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "revocation lookup")
+	}
+
+	uid, _ := claims["uid"].(float64)
+	sid, _ := claims["sid"].(string)
+	email, _ := claims["email"].(string)
+	exp, _ := claims["exp"].(float64)
+	mfa, _ := claims["mfa"].(bool)
+
+	return &authv1.IntrospectResponse{
+		Active:    !revoked,
+		UserId:    int64(uid),
+		SessionId: sid,
+		Email:     email,
+		Roles:     stringSlice(claims["roles"]),
+		ExpiresAt: int64(exp),
+		Revoked:   revoked,
+		Mfa:       mfa,
+	}, nil
+}
+
+// tokenRevoked reports whether the token was revoked on its own (logout),
+// with its session, together with every other token of its user (password
+// reset, logout everywhere), or whether its user is disabled.
+func (g *GRPCserver) tokenRevoked(ctx context.Context, claims gojwt.MapClaims) (bool, error) {
+	jti, _ := claims["jti"].(string)
+	revoked, err := g.store.IsAccessTokenRevoked(ctx, jti)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	if sid, _ := claims["sid"].(string); sid != "" {
+		revoked, err := g.store.IsSessionRevoked(ctx, sid)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	uid, _ := claims["uid"].(float64)
+	user, err := g.store.UserByID(ctx, int64(uid))
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if user.Disabled {
+		return true, nil
+	}
+
+	revokedAt, err := g.store.TokensRevokedAt(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	return issuedAt(claims).Before(revokedAt), nil
+}
+
+// issuedAt returns the iat claim, which carries milliseconds so that a
+// token issued in the same second as a revocation is still ordered
+// against it.
+func issuedAt(claims gojwt.MapClaims) time.Time {
+	iat, _ := claims["iat"].(float64)
+	return time.UnixMilli(int64(math.Round(iat * 1000)))
+}
+
+// revokeAccessToken records the jti of a still valid access token so that
+// Introspect reports it as revoked until it expires.
+func (g *GRPCserver) revokeAccessToken(ctx context.Context, token string) error {
+	claims, err := jwt.Parse(g.keyring, token)
+	if err != nil {
+		return nil
+	}
+
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if jti == "" {
+		return nil
+	}
+	return g.store.RevokeAccessToken(ctx, jti, time.Unix(int64(exp), 0))
+}
+
+func stringSlice(v interface{}) []string {
+	switch raw := v.(type) {
+	case []string:
+		return raw
+	case []interface{}:
+		out := make([]string, 0, len(raw))
+		for _, r := range raw {
+			if s, ok := r.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	"github.com/barcek2281/comics-store/pkg/validate"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	magicLinkTTL       = time.Minute * 15
+	magicLinkMailTitle = "Your comics-store sign-in link"
+)
+
+// RequestMagicLink mails a single-use sign-in link. Like password reset it
+// reports success whether or not the email is registered.
+func (g *GRPCserver) RequestMagicLink(ctx context.Context, in *authv1.RequestMagicLinkRequest) (*authv1.RequestMagicLinkResponse, error) {
+	if err := validate.Struct(emailInput{Email: in.Email}); err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.User(ctx, in.Email)
+	if err != nil {
+		if !errors.Is(err, storage.ErrUserNotFound) {
+			slog.ErrorContext(ctx, "cannot look up user for magic link", "error", err)
+		}
+		return &authv1.RequestMagicLinkResponse{Success: true}, nil
+	}
+	if user.Disabled {
+		return &authv1.RequestMagicLinkResponse{Success: true}, nil
+	}
+
+	token, err := jwt.NewPurposeToken(g.keyring, jwt.PurposeMagicLink, user, magicLinkTTL)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "jwt issue")
+	}
+
+	link := fmt.Sprintf("%s/auth/magic-link/consume?token=%s", g.publicURL, url.QueryEscape(token))
+	err = g.store.EnqueueMail(ctx, model.OutboxMessage{
+		Recipient: user.Email,
+		Subject:   magicLinkMailTitle,
+		Body:      fmt.Sprintf("Sign in to comics-store by opening this link:\n\n%s\n\nThe link works once and expires in 15 minutes. If you did not ask for it, ignore this email.", link),
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "queue magic link mail")
+	}
+
+	return &authv1.RequestMagicLinkResponse{Success: true}, nil
+}
+
+// ConsumeMagicLink exchanges a magic link token for a token pair, or for a
+// second factor challenge when the user has TOTP enabled. Opening the link
+// also proves the user owns the address, so the email becomes verified.
+func (g *GRPCserver) ConsumeMagicLink(ctx context.Context, in *authv1.ConsumeMagicLinkRequest) (*authv1.ConsumeMagicLinkResponse, error) {
+	claims, err := jwt.ParsePurposeToken(g.keyring, in.Token, jwt.PurposeMagicLink)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired magic link")
+	}
+
+	uid, _ := claims["uid"].(float64)
+	email, _ := claims["email"].(string)
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+
+	user, err := g.store.UserByID(ctx, int64(uid))
+	if err != nil || user.Email != email || jti == "" {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired magic link")
+	}
+	if user.Disabled {
+		return nil, errAccountDisabled
+	}
+
+	err = g.store.ConsumeTokenID(ctx, jti, time.Unix(int64(exp), 0))
+	if errors.Is(err, storage.ErrTokenUsed) {
+		return nil, status.Error(codes.Unauthenticated, "magic link already used")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "consume magic link")
+	}
+
+	if !user.EmailVerified {
+		if err := g.store.SetEmailVerified(ctx, user.ID); err != nil {
+			return nil, status.Error(codes.Internal, "verify email")
+		}
+		user.EmailVerified = true
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := jwt.NewPurposeToken(g.keyring, jwt.PurposeTOTPChallenge, user, totpChallengeTTL)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "jwt issue")
+		}
+		return &authv1.ConsumeMagicLinkResponse{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	dev := deviceFrom(ctx)
+	token, refreshToken, err := g.startSession(ctx, user, dev, false)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "jwt issue")
+	}
+	g.loginSucceeded(ctx, dev, user, "magic_link")
+
+	return &authv1.ConsumeMagicLinkResponse{Token: token, RefreshToken: refreshToken}, nil
+}
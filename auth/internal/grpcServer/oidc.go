@@ -0,0 +1,183 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/oidc"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const oidcStateTTL = time.Minute * 10
+
+// StartOIDCLogin begins the authorization code flow with a provider and
+// returns the URL the browser should be redirected to.
+func (g *GRPCserver) StartOIDCLogin(ctx context.Context, in *authv1.StartOIDCLoginRequest) (*authv1.StartOIDCLoginResponse, error) {
+	provider, ok := g.providers[in.Provider]
+	if !ok {
+		return nil, status.Error(codes.NotFound, oidc.ErrUnknownProvider.Error())
+	}
+
+	state, err := jwt.NewID()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "oidc state")
+	}
+	nonce, err := jwt.NewID()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "oidc nonce")
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "oidc verifier")
+	}
+
+	authURL, err := provider.AuthURL(ctx, state, nonce, verifier)
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot build oidc authorization url", "provider", in.Provider, "error", err)
+		return nil, status.Error(codes.Unavailable, "identity provider unavailable")
+	}
+
+	err = g.store.SaveOIDCState(ctx, model.OIDCState{
+		State:        state,
+		Provider:     in.Provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "save oidc state")
+	}
+
+	return &authv1.StartOIDCLoginResponse{AuthorizationUrl: authURL}, nil
+}
+
+// FinishOIDCLogin handles the provider callback: it exchanges the code,
+// verifies the ID token and signs in the linked user, linking or creating
+// one by verified email on first sign-in.
+func (g *GRPCserver) FinishOIDCLogin(ctx context.Context, in *authv1.FinishOIDCLoginRequest) (*authv1.FinishOIDCLoginResponse, error) {
+	provider, ok := g.providers[in.Provider]
+	if !ok {
+		return nil, status.Error(codes.NotFound, oidc.ErrUnknownProvider.Error())
+	}
+
+	state, err := g.store.ConsumeOIDCState(ctx, in.State)
+	if errors.Is(err, storage.ErrTokenNotFound) || (err == nil && state.Provider != in.Provider) {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired state")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "oidc state lookup")
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, in.Code, state.CodeVerifier)
+	if err != nil {
+		slog.WarnContext(ctx, "oidc code exchange failed", "provider", in.Provider, "error", err)
+		return nil, status.Error(codes.Unauthenticated, "code exchange failed")
+	}
+	identity, err := provider.Verify(ctx, rawIDToken, state.Nonce)
+	if err != nil {
+		slog.WarnContext(ctx, "oidc id token rejected", "provider", in.Provider, "error", err)
+		return nil, status.Error(codes.Unauthenticated, "invalid id token")
+	}
+
+	user, err := g.oidcUser(ctx, in.Provider, identity)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, errAccountDisabled
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := jwt.NewPurposeToken(g.keyring, jwt.PurposeTOTPChallenge, user, totpChallengeTTL)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "jwt issue")
+		}
+		return &authv1.FinishOIDCLoginResponse{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	dev := deviceFrom(ctx)
+	token, refreshToken, err := g.startSession(ctx, user, dev, false)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "jwt issue")
+	}
+	g.loginSucceeded(ctx, dev, user, "oidc:"+in.Provider)
+
+	return &authv1.FinishOIDCLoginResponse{Token: token, RefreshToken: refreshToken}, nil
+}
+
+// oidcUser resolves the local user behind a provider identity. Unknown
+// identities are linked to the account with the same email, or to a new
+// passwordless account, but only if the provider verified the email. An
+// existing account whose email was never verified locally is taken over:
+// its password and sessions are invalidated before it is linked.
+func (g *GRPCserver) oidcUser(ctx context.Context, provider string, identity oidc.Identity) (model.User, error) {
+	link, err := g.store.UserIdentity(ctx, provider, identity.Subject)
+	if err == nil {
+		user, err := g.store.UserByID(ctx, link.UserID)
+		if err != nil {
+			return model.User{}, status.Error(codes.Internal, "user lookup")
+		}
+		return user, nil
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return model.User{}, status.Error(codes.Internal, "identity lookup")
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return model.User{}, status.Error(codes.FailedPrecondition, "identity provider did not verify the email")
+	}
+
+	user, err := g.store.User(ctx, identity.Email)
+	existing := err == nil
+	if errors.Is(err, storage.ErrUserNotFound) {
+		// No password: the account can only sign in through a provider,
+		// a magic link or after a password reset.
+		user = model.User{
+			Email: identity.Email,
+			Roles: []string{model.RoleCustomer},
+		}
+		user.ID, err = g.store.Save(user)
+	}
+	if err != nil {
+		return model.User{}, status.Error(codes.Internal, "user lookup")
+	}
+
+	if existing && !user.EmailVerified {
+		// Anyone could have registered the unverified account with this
+		// email before its owner signed in here. Drop its password and
+		// sessions so such a squatter loses access once it is linked.
+		if err := g.store.UpdatePassword(ctx, user.ID, ""); err != nil {
+			return model.User{}, status.Error(codes.Internal, "update password")
+		}
+		if err := g.store.RevokeUserTokens(ctx, user.ID); err != nil {
+			return model.User{}, status.Error(codes.Internal, "revoke sessions")
+		}
+		user.Password = ""
+	}
+	if !user.EmailVerified {
+		if err := g.store.SetEmailVerified(ctx, user.ID); err != nil {
+			return model.User{}, status.Error(codes.Internal, "verify email")
+		}
+		user.EmailVerified = true
+	}
+
+	err = g.store.SaveUserIdentity(ctx, model.Identity{
+		Provider:  provider,
+		Subject:   identity.Subject,
+		UserID:    user.ID,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return model.User{}, status.Error(codes.Internal, "link identity")
+	}
+
+	return user, nil
+}
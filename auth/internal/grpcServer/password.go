@@ -0,0 +1,184 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/lib/password"
+	"github.com/barcek2281/comics-store/auth/internal/lockout"
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	"github.com/barcek2281/comics-store/pkg/validate"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const passwordResetTTL = time.Hour
+
+// RequestPasswordReset mails a single-use reset link. It reports success
+// whether or not the email is registered.
+func (g *GRPCserver) RequestPasswordReset(ctx context.Context, in *authv1.RequestPasswordResetRequest) (*authv1.RequestPasswordResetResponse, error) {
+	if err := validate.Struct(emailInput{Email: in.Email}); err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.User(ctx, in.Email)
+	if err != nil {
+		if !errors.Is(err, storage.ErrUserNotFound) {
+			slog.ErrorContext(ctx, "cannot look up user for password reset", "error", err)
+		}
+		return &authv1.RequestPasswordResetResponse{Success: true}, nil
+	}
+
+	token, hash, err := jwt.NewOpaqueToken()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "reset token")
+	}
+
+	err = g.store.SavePasswordReset(ctx, model.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "save reset token")
+	}
+
+	link := fmt.Sprintf("%s/auth/password/reset?token=%s", g.publicURL, url.QueryEscape(token))
+	err = g.store.EnqueueMail(ctx, model.OutboxMessage{
+		Recipient: user.Email,
+		Subject:   "Reset your comics-store password",
+		Body:      fmt.Sprintf("Someone asked to reset your password. If it was you, open this link within an hour:\n\n%s\n\nOtherwise you can ignore this email.", link),
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "queue reset mail")
+	}
+
+	return &authv1.RequestPasswordResetResponse{Success: true}, nil
+}
+
+// ResetPassword sets a new password and revokes every existing session of
+// the user.
+func (g *GRPCserver) ResetPassword(ctx context.Context, in *authv1.ResetPasswordRequest) (*authv1.ResetPasswordResponse, error) {
+	reset, err := g.store.PasswordReset(ctx, jwt.HashToken(in.Token))
+	if errors.Is(err, storage.ErrTokenNotFound) {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired reset token")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "reset token lookup")
+	}
+	if reset.Used || time.Now().After(reset.ExpiresAt) {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired reset token")
+	}
+
+	if err := checkPassword(g.policy, "new_password", in.NewPassword, ""); err != nil {
+		return nil, err
+	}
+
+	hash, err := g.passwords.Hash(in.NewPassword)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "hash password")
+	}
+
+	err = g.store.UsePasswordReset(ctx, reset.ID)
+	if errors.Is(err, storage.ErrTokenUsed) {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired reset token")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "consume reset token")
+	}
+
+	if err := g.store.UpdatePassword(ctx, reset.UserID, hash); err != nil {
+		return nil, status.Error(codes.Internal, "update password")
+	}
+	if err := g.store.RevokeUserTokens(ctx, reset.UserID); err != nil {
+		return nil, status.Error(codes.Internal, "revoke sessions")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventPasswordChange, UserID: reset.UserID, Success: true, Detail: "reset"})
+
+	return &authv1.ResetPasswordResponse{Success: true}, nil
+}
+
+func (g *GRPCserver) ChangePassword(ctx context.Context, in *authv1.ChangePasswordRequest) (*authv1.ChangePasswordResponse, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.UserByID(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	// A stolen access token must not become a password oracle, so wrong
+	// current passwords count towards the same lockout as failed logins.
+	key := lockout.EmailKey(user.Email)
+	if wait := g.guard.Check(ctx, key); wait > 0 {
+		return nil, tooManyAttempts(wait)
+	}
+	if ok, _, _ := g.passwords.Verify(user.Password, in.CurrentPassword); !ok {
+		g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventPasswordChange, UserID: user.ID, ActorID: user.ID, Email: user.Email, Success: false, Detail: "wrong_password"})
+		if wait := g.guard.Fail(ctx, key); wait > 0 {
+			return nil, tooManyAttempts(wait)
+		}
+		return nil, status.Error(codes.InvalidArgument, "current password is wrong")
+	}
+	g.guard.Reset(ctx, key)
+	if err := checkPassword(g.policy, "new_password", in.NewPassword, user.Email); err != nil {
+		return nil, err
+	}
+
+	hash, err := g.passwords.Hash(in.NewPassword)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "hash password")
+	}
+	if err := g.store.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return nil, status.Error(codes.Internal, "update password")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventPasswordChange, UserID: user.ID, ActorID: user.ID, Email: user.Email, Success: true, Detail: "change"})
+
+	return &authv1.ChangePasswordResponse{Success: true}, nil
+}
+
+// rehashPassword upgrades the stored hash after a successful login. Failing
+// to do so is not fatal: the old hash keeps working.
+func (g *GRPCserver) rehashPassword(ctx context.Context, userID int64, plain string) {
+	hash, err := g.passwords.Hash(plain)
+	if err == nil {
+		err = g.store.UpdatePassword(ctx, userID, hash)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot rehash password", "user_id", userID, "error", err)
+	}
+}
+
+// checkPassword turns policy violations into an InvalidArgument status with
+// one field violation per broken rule.
+func checkPassword(policy *password.Policy, field, plain, email string) error {
+	violations := policy.Check(field, plain, email)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	details := &errdetails.BadRequest{}
+	for _, v := range violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("%s does not meet the password policy", field))
+	detailed, err := st.WithDetails(details)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,161 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/lockout"
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	"github.com/barcek2281/comics-store/pkg/validate"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func (g *GRPCserver) GetMe(ctx context.Context, in *authv1.GetMeRequest) (*authv1.UserProfile, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.UserByID(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	return toProfile(user), nil
+}
+
+func (g *GRPCserver) UpdateProfile(ctx context.Context, in *authv1.UpdateProfileRequest) (*authv1.UserProfile, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(in.PreferredCurrency))
+	if currency != "" && !currencyRe.MatchString(currency) {
+		return nil, status.Error(codes.InvalidArgument, "preferred_currency must be a 3 letter ISO 4217 code")
+	}
+
+	user, err := g.store.UserByID(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	user.DisplayName = strings.TrimSpace(in.DisplayName)
+	user.Phone = strings.TrimSpace(in.Phone)
+	if currency != "" {
+		user.PreferredCurrency = currency
+	}
+
+	if err := g.store.UpdateProfile(ctx, user); err != nil {
+		return nil, status.Error(codes.Internal, "update profile")
+	}
+	user.UpdatedAt = time.Now()
+
+	return toProfile(user), nil
+}
+
+// ChangeEmail mails a confirmation link to the new address. The account
+// keeps the old address until the link is opened, and the old address is
+// told about the change, so a stolen session cannot quietly take the
+// account over.
+func (g *GRPCserver) ChangeEmail(ctx context.Context, in *authv1.ChangeEmailRequest) (*authv1.ChangeEmailResponse, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(newEmailInput{NewEmail: in.NewEmail}); err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.UserByID(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if err := g.verifyPassword(ctx, user, in.Password); err != nil {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(in.NewEmail)
+	if email == "" || email == user.Email {
+		return nil, status.Error(codes.InvalidArgument, "new_email must differ from the current email")
+	}
+	_, err = g.store.User(ctx, email)
+	if err == nil {
+		return nil, status.Error(codes.AlreadyExists, "email is used")
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return nil, status.Error(codes.Internal, "user lookup")
+	}
+
+	if err := g.sendEmailChange(ctx, user, email); err != nil {
+		return nil, status.Error(codes.Internal, "queue email change mail")
+	}
+
+	return &authv1.ChangeEmailResponse{Success: true}, nil
+}
+
+// DeleteAccount removes the caller's account after re-checking the
+// password. Outstanding tokens die with the user row.
+func (g *GRPCserver) DeleteAccount(ctx context.Context, in *authv1.DeleteAccountRequest) (*authv1.DeleteAccountResponse, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.UserByID(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if err := g.verifyPassword(ctx, user, in.Password); err != nil {
+		return nil, err
+	}
+
+	if err := g.store.DeleteUser(ctx, user.ID); err != nil {
+		return nil, status.Error(codes.Internal, "delete account")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventAccountDeleted, UserID: user.ID, ActorID: user.ID, Email: user.Email, Success: true})
+
+	return &authv1.DeleteAccountResponse{Success: true}, nil
+}
+
+// verifyPassword re-checks the password of a signed-in user. A stolen
+// access token must not become a password oracle, so wrong passwords count
+// towards the same lockout as failed logins.
+func (g *GRPCserver) verifyPassword(ctx context.Context, user model.User, plain string) error {
+	key := lockout.EmailKey(user.Email)
+	if wait := g.guard.Check(ctx, key); wait > 0 {
+		return tooManyAttempts(wait)
+	}
+	if ok, _, _ := g.passwords.Verify(user.Password, plain); !ok {
+		if wait := g.guard.Fail(ctx, key); wait > 0 {
+			return tooManyAttempts(wait)
+		}
+		return status.Error(codes.InvalidArgument, "password is wrong")
+	}
+	g.guard.Reset(ctx, key)
+	return nil
+}
+
+func toProfile(user model.User) *authv1.UserProfile {
+	return &authv1.UserProfile{
+		Id:                user.ID,
+		Email:             user.Email,
+		EmailVerified:     user.EmailVerified,
+		Roles:             user.Roles,
+		DisplayName:       user.DisplayName,
+		Phone:             user.Phone,
+		PreferredCurrency: user.PreferredCurrency,
+		TotpEnabled:       user.TOTPEnabled,
+		Disabled:          user.Disabled,
+		CreatedAt:         user.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:         user.UpdatedAt.Format(time.RFC3339),
+	}
+}
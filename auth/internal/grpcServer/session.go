@@ -0,0 +1,82 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListSessions returns the caller's live sessions, marking the one the
+// request was made with.
+func (g *GRPCserver) ListSessions(ctx context.Context, in *authv1.ListSessionsRequest) (*authv1.ListSessionsResponse, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := g.store.Sessions(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "list sessions")
+	}
+
+	out := make([]*authv1.Session, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, &authv1.Session{
+			Id:         s.ID,
+			UserAgent:  s.UserAgent,
+			Ip:         s.IP,
+			CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: s.LastSeenAt.Format(time.RFC3339),
+			Current:    s.ID == c.SessionID,
+		})
+	}
+	return &authv1.ListSessionsResponse{Sessions: out}, nil
+}
+
+func (g *GRPCserver) RevokeSession(ctx context.Context, in *authv1.RevokeSessionRequest) (*authv1.RevokeSessionResponse, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.store.RevokeSession(ctx, c.UserID, in.SessionId)
+	if errors.Is(err, storage.ErrTokenNotFound) {
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "revoke session")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventTokenRevoked, UserID: c.UserID, ActorID: c.UserID, Success: true, Detail: "session:" + in.SessionId})
+
+	return &authv1.RevokeSessionResponse{Success: true}, nil
+}
+
+// RevokeAllSessions signs the caller out everywhere, including the current
+// session, and returns the ids of the sessions it ended.
+func (g *GRPCserver) RevokeAllSessions(ctx context.Context, in *authv1.RevokeAllSessionsRequest) (*authv1.RevokeAllSessionsResponse, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := g.store.Sessions(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "list sessions")
+	}
+	if err := g.store.RevokeUserTokens(ctx, c.UserID); err != nil {
+		return nil, status.Error(codes.Internal, "revoke sessions")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventTokenRevoked, UserID: c.UserID, ActorID: c.UserID, Success: true, Detail: "all_sessions"})
+
+	ids := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	return &authv1.RevokeAllSessionsResponse{SessionIds: ids}, nil
+}
@@ -0,0 +1,202 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/lib/totp"
+	"github.com/barcek2281/comics-store/auth/internal/lockout"
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"github.com/skip2/go-qrcode"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	totpIssuer        = "comics-store"
+	totpChallengeTTL  = time.Minute * 5
+	recoveryCodeCount = 10
+)
+
+// EnrollTOTP generates a new pending secret for the caller. Logins are not
+// affected until ConfirmTOTP proves the authenticator app works.
+func (g *GRPCserver) EnrollTOTP(ctx context.Context, in *authv1.EnrollTOTPRequest) (*authv1.EnrollTOTPResponse, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.UserByID(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, status.Error(codes.FailedPrecondition, "two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "totp secret")
+	}
+	if err := g.store.SetTOTPSecret(ctx, user.ID, secret); err != nil {
+		return nil, status.Error(codes.Internal, "save totp secret")
+	}
+
+	uri := totp.URI(totpIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "qr code")
+	}
+
+	return &authv1.EnrollTOTPResponse{
+		Secret:     secret,
+		OtpauthUri: uri,
+		QrPng:      png,
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA once the caller submits a valid code for the
+// pending secret, and returns freshly generated recovery codes. The codes
+// are shown only this once; the database keeps their hashes.
+func (g *GRPCserver) ConfirmTOTP(ctx context.Context, in *authv1.ConfirmTOTPRequest) (*authv1.ConfirmTOTPResponse, error) {
+	c, err := g.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.UserByID(ctx, c.UserID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if user.TOTPSecret == "" || user.TOTPEnabled {
+		return nil, status.Error(codes.FailedPrecondition, "no pending two-factor enrollment")
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, in.Code, time.Now())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid code")
+	}
+
+	recovery, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "recovery codes")
+	}
+	if err := g.store.EnableTOTP(ctx, user.ID, hashes); err != nil {
+		return nil, status.Error(codes.Internal, "enable totp")
+	}
+	if err := g.store.UseTOTPStep(ctx, user.ID, step); err != nil && !errors.Is(err, storage.ErrTokenUsed) {
+		return nil, status.Error(codes.Internal, "save totp step")
+	}
+	g.record(ctx, deviceFrom(ctx), model.AuditEvent{Type: model.EventTwoFactorEnabled, UserID: user.ID, Email: user.Email, Success: true})
+
+	return &authv1.ConfirmTOTPResponse{RecoveryCodes: recovery}, nil
+}
+
+// VerifySecondFactor completes a login that Login answered with a
+// challenge, accepting either a TOTP code or an unused recovery code.
+// A challenge allows a single attempt, and failed attempts count towards a
+// per-user lockout, so codes cannot be guessed across many challenges.
+func (g *GRPCserver) VerifySecondFactor(ctx context.Context, in *authv1.VerifySecondFactorRequest) (*authv1.VerifySecondFactorResponse, error) {
+	claims, err := jwt.ParsePurposeToken(g.keyring, in.ChallengeToken, jwt.PurposeTOTPChallenge)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired challenge")
+	}
+
+	uid, _ := claims["uid"].(float64)
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	user, err := g.store.UserByID(ctx, int64(uid))
+	if err != nil || !user.TOTPEnabled || jti == "" {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired challenge")
+	}
+	if user.Disabled {
+		return nil, errAccountDisabled
+	}
+
+	key := lockout.SecondFactorKey(user.ID)
+	if wait := g.guard.Check(ctx, key); wait > 0 {
+		return nil, tooManyAttempts(wait)
+	}
+
+	err = g.store.ConsumeTokenID(ctx, jti, time.Unix(int64(exp), 0))
+	if errors.Is(err, storage.ErrTokenUsed) {
+		return nil, status.Error(codes.Unauthenticated, "challenge already used")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "consume challenge")
+	}
+
+	dev := deviceFrom(ctx)
+	method := "totp"
+	switch {
+	case in.RecoveryCode != "":
+		method = "recovery_code"
+		err := g.store.UseRecoveryCode(ctx, user.ID, jwt.HashToken(normalizeRecoveryCode(in.RecoveryCode)))
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			g.loginFailed(ctx, dev, user.ID, user.Email, "invalid_recovery_code")
+			return nil, g.secondFactorFailed(ctx, key, "invalid recovery code")
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, "use recovery code")
+		}
+	default:
+		step, ok := totp.Validate(user.TOTPSecret, in.Code, time.Now())
+		if !ok {
+			g.loginFailed(ctx, dev, user.ID, user.Email, "invalid_totp_code")
+			return nil, g.secondFactorFailed(ctx, key, "invalid code")
+		}
+		err := g.store.UseTOTPStep(ctx, user.ID, step)
+		if errors.Is(err, storage.ErrTokenUsed) {
+			return nil, status.Error(codes.Unauthenticated, "code already used")
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, "save totp step")
+		}
+	}
+
+	g.guard.Reset(ctx, key)
+
+	token, refreshToken, err := g.startSession(ctx, user, dev, true)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "jwt issue")
+	}
+	g.loginSucceeded(ctx, dev, user, method)
+
+	return &authv1.VerifySecondFactorResponse{Token: token, RefreshToken: refreshToken}, nil
+}
+
+// secondFactorFailed records a wrong code against key and returns the
+// error to answer with.
+func (g *GRPCserver) secondFactorFailed(ctx context.Context, key, msg string) error {
+	if wait := g.guard.Fail(ctx, key); wait > 0 {
+		return tooManyAttempts(wait)
+	}
+	return status.Error(codes.Unauthenticated, msg)
+}
+
+// newRecoveryCodes returns codes formatted as xxxxx-xxxxx and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		plain = append(plain, code[:5]+"-"+code[5:])
+		hashes = append(hashes, jwt.HashToken(code))
+	}
+	return plain, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
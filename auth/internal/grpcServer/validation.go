@@ -0,0 +1,18 @@
+package grpcserver
+
+// These inputs carry the rules the gateway applies to the same payloads, so
+// callers that reach the service directly are held to them too. Password
+// strength is checked separately by checkPassword.
+
+type emailInput struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+type newEmailInput struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=254"`
+}
+
+type credentialsInput struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required"`
+}
@@ -0,0 +1,130 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	"github.com/barcek2281/comics-store/pkg/validate"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	verificationTokenTTL  = time.Hour * 24
+	verificationMailTitle = "Confirm your comics-store email"
+)
+
+func (g *GRPCserver) VerifyEmail(ctx context.Context, in *authv1.VerifyEmailRequest) (*authv1.VerifyEmailResponse, error) {
+	if claims, err := jwt.ParsePurposeToken(g.keyring, in.Token, jwt.PurposeChangeEmail); err == nil {
+		return g.confirmEmailChange(ctx, claims)
+	}
+
+	claims, err := jwt.ParsePurposeToken(g.keyring, in.Token, jwt.PurposeVerifyEmail)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired verification token")
+	}
+
+	uid, _ := claims["uid"].(float64)
+	email, _ := claims["email"].(string)
+
+	user, err := g.store.UserByID(ctx, int64(uid))
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "user lookup")
+	}
+	// A token issued for an address the user has since changed away from
+	// must not verify the new one.
+	if user.Email != email {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired verification token")
+	}
+
+	if err := g.store.SetEmailVerified(ctx, user.ID); err != nil {
+		return nil, status.Error(codes.Internal, "verify email")
+	}
+
+	return &authv1.VerifyEmailResponse{Success: true}, nil
+}
+
+// ResendVerification always reports success so it cannot be used to find
+// out which emails are registered.
+func (g *GRPCserver) ResendVerification(ctx context.Context, in *authv1.ResendVerificationRequest) (*authv1.ResendVerificationResponse, error) {
+	if err := validate.Struct(emailInput{Email: in.Email}); err != nil {
+		return nil, err
+	}
+
+	user, err := g.store.User(ctx, in.Email)
+	if err == nil && !user.EmailVerified {
+		if err := g.sendVerification(ctx, user); err != nil {
+			slog.ErrorContext(ctx, "cannot queue verification mail", "error", err)
+		}
+	}
+
+	return &authv1.ResendVerificationResponse{Success: true}, nil
+}
+
+func (g *GRPCserver) sendVerification(ctx context.Context, user model.User) error {
+	token, err := jwt.NewPurposeToken(g.keyring, jwt.PurposeVerifyEmail, user, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/auth/verify-email?token=%s", g.publicURL, url.QueryEscape(token))
+	return g.store.EnqueueMail(ctx, model.OutboxMessage{
+		Recipient: user.Email,
+		Subject:   verificationMailTitle,
+		Body:      fmt.Sprintf("Welcome to comics-store!\n\nConfirm your email address by opening this link:\n\n%s\n\nThe link is valid for 24 hours.", link),
+	})
+}
+
+// sendEmailChange mails a link that moves user to email, and tells the
+// current address about it.
+func (g *GRPCserver) sendEmailChange(ctx context.Context, user model.User, email string) error {
+	next := user
+	next.Email = email
+	token, err := jwt.NewPurposeToken(g.keyring, jwt.PurposeChangeEmail, next, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/auth/verify-email?token=%s", g.publicURL, url.QueryEscape(token))
+	err = g.store.EnqueueMail(ctx, model.OutboxMessage{
+		Recipient: email,
+		Subject:   verificationMailTitle,
+		Body:      fmt.Sprintf("Confirm that your comics-store account should use this address by opening this link:\n\n%s\n\nThe link is valid for 24 hours.", link),
+	})
+	if err != nil {
+		return err
+	}
+	return g.store.EnqueueMail(ctx, model.OutboxMessage{
+		Recipient: user.Email,
+		Subject:   "Your comics-store email is about to change",
+		Body:      fmt.Sprintf("Someone asked to move your comics-store account to %s. The change only happens once the link sent there is opened.\n\nIf it was not you, change your password now and sign out of all sessions.", email),
+	})
+}
+
+// confirmEmailChange moves the account to the address the change token was
+// mailed to, which the user has now proven to own.
+func (g *GRPCserver) confirmEmailChange(ctx context.Context, claims map[string]interface{}) (*authv1.VerifyEmailResponse, error) {
+	uid, _ := claims["uid"].(float64)
+	email, _ := claims["email"].(string)
+
+	err := g.store.ChangeEmail(ctx, int64(uid), email)
+	if errors.Is(err, storage.ErrUserExists) {
+		return nil, status.Error(codes.AlreadyExists, "email is used")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "change email")
+	}
+
+	return &authv1.VerifyEmailResponse{Success: true}, nil
+}
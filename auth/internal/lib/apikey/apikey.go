@@ -0,0 +1,39 @@
+// Package apikey generates and parses API keys of the form
+// csk_<prefix>_<secret>. The prefix identifies the key and is stored in
+// the clear; the secret is only ever stored hashed.
+package apikey
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+)
+
+const scheme = "csk"
+
+// Generate returns a new key together with its prefix and the hash of its
+// secret.
+func Generate() (key, prefix, secretHash string, err error) {
+	p := make([]byte, 4)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", "", err
+	}
+	secret, err := jwt.NewID()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(p)
+	return scheme + "_" + prefix + "_" + secret, prefix, jwt.HashToken(secret), nil
+}
+
+// Split breaks a key into its prefix and secret.
+func Split(key string) (prefix, secret string, ok bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != scheme || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
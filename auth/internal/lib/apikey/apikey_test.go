@@ -0,0 +1,67 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/barcek2281/comics-store/auth/internal/lib/jwt"
+)
+
+func TestGenerate(t *testing.T) {
+	key, prefix, secretHash, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(key, "csk_"+prefix+"_") {
+		t.Errorf("key %q does not start with csk_%s_", key, prefix)
+	}
+	if len(prefix) != 8 {
+		t.Errorf("prefix %q has %d characters, want 8", prefix, len(prefix))
+	}
+
+	gotPrefix, secret, ok := Split(key)
+	if !ok || gotPrefix != prefix {
+		t.Fatalf("Split(%q) = (%q, %q, %v)", key, gotPrefix, secret, ok)
+	}
+	if jwt.HashToken(secret) != secretHash {
+		t.Error("secret hash does not match the secret of the key")
+	}
+	if strings.Contains(secretHash, secret) {
+		t.Error("secret hash contains the secret")
+	}
+
+	other, _, _, _ := Generate()
+	if other == key {
+		t.Error("Generate returned the same key twice")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		wantPrefix string
+		wantSecret string
+		wantOK     bool
+	}{
+		{"valid", "csk_0a1b2c3d_s3cret", "0a1b2c3d", "s3cret", true},
+		{"wrong scheme", "sk_0a1b2c3d_s3cret", "", "", false},
+		{"missing secret", "csk_0a1b2c3d_", "", "", false},
+		{"missing prefix", "csk__s3cret", "", "", false},
+		{"too few parts", "csk_0a1b2c3d", "", "", false},
+		{"too many parts", "csk_0a1b2c3d_s3_cret", "", "", false},
+		{"empty", "", "", "", false},
+		{"bearer token", "eyJhbGciOiJSUzI1NiJ9.e30.sig", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, secret, ok := Split(tt.key)
+			if prefix != tt.wantPrefix || secret != tt.wantSecret || ok != tt.wantOK {
+				t.Errorf("Split(%q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.key, prefix, secret, ok, tt.wantPrefix, tt.wantSecret, tt.wantOK)
+			}
+		})
+	}
+}
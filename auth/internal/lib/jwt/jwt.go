@@ -1,24 +1,152 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"time"
 
 	"github.com/barcek2281/comics-store/auth/internal/model"
 	"github.com/golang-jwt/jwt"
 )
 
-func NewToken(secret string, user model.User, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+// Purposes of single-use tokens. They are signed with the same keyring as
+// access tokens but carry a purpose claim, so they can never be used as
+// access tokens and vice versa.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeChangeEmail   = "change_email"
+	PurposeTOTPChallenge = "totp_challenge"
+	PurposeMagicLink     = "magic_link"
+)
+
+// NewToken signs an access token for user. sid ties it to the session it
+// was issued for, so ending the session revokes the token. mfa tells
+// whether that session was opened with the second factor.
+func NewToken(keyring *Keyring, user model.User, sid string, mfa bool, duration time.Duration) (string, error) {
+	key, err := keyring.Current()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.New(jwt.SigningMethodEdDSA)
+	token.Header["kid"] = key.Kid
 
 	claims := token.Claims.(jwt.MapClaims)
 
+	jti, err := NewID()
+	if err != nil {
+		return "", err
+	}
+
+	claims["jti"] = jti
 	claims["uid"] = user.ID
+	claims["sid"] = sid
 	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(duration).Unix()
+	claims["roles"] = user.Roles
+	claims["email_verified"] = user.EmailVerified
+	claims["mfa"] = mfa
+	// iat keeps milliseconds, see RevokeUserTokens.
+	now := time.Now()
+	claims["iat"] = float64(now.UnixMilli()) / 1000
+	claims["exp"] = now.Add(duration).Unix()
 
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := token.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
+
+// Parse verifies an access token signed by one of the keyring's keys and
+// returns its claims.
+func Parse(keyring *Keyring, tokenString string) (jwt.MapClaims, error) {
+	claims, err := parse(keyring, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := claims["purpose"]; ok {
+		return nil, fmt.Errorf("not an access token")
+	}
+	return claims, nil
+}
+
+// NewPurposeToken signs a short-lived token that only authorises the
+// given purpose for user.
+func NewPurposeToken(keyring *Keyring, purpose string, user model.User, duration time.Duration) (string, error) {
+	key, err := keyring.Current()
+	if err != nil {
+		return "", err
+	}
+
+	jti, err := NewID()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"jti":     jti,
+		"uid":     user.ID,
+		"email":   user.Email,
+		"purpose": purpose,
+		"exp":     time.Now().Add(duration).Unix(),
+	})
+	token.Header["kid"] = key.Kid
+
+	return token.SignedString(key.PrivateKey)
+}
+
+func ParsePurposeToken(keyring *Keyring, tokenString string, purpose string) (jwt.MapClaims, error) {
+	claims, err := parse(keyring, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims["purpose"] != purpose {
+		return nil, fmt.Errorf("token is not valid for %s", purpose)
+	}
+	return claims, nil
+}
+
+func parse(keyring *Keyring, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keyring.PublicKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// NewOpaqueToken returns a random opaque token (refresh, reset, ...) and
+// the hash that should be persisted in its place.
+func NewOpaqueToken() (token string, hash string, err error) {
+	token, err = NewID()
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewID returns a random hex identifier suitable for jti and token family ids.
+func NewID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+)
+
+type KeyStore interface {
+	SaveSigningKey(ctx context.Context, key model.SigningKey) error
+	SigningKeys(ctx context.Context) ([]model.SigningKey, error)
+	DeleteExpiredSigningKeys(ctx context.Context) error
+}
+
+// JWK is the public half of a signing key in RFC 8037 (OKP) form.
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// Keyring holds the current signing key plus older keys that are still
+// published for verification. A new key is generated every rotateEvery and
+// old keys are kept for retainFor after they stop signing.
+type Keyring struct {
+	store       KeyStore
+	rotateEvery time.Duration
+	retainFor   time.Duration
+
+	mu   sync.RWMutex
+	keys []model.SigningKey
+}
+
+func NewKeyring(store KeyStore, rotateEvery, retainFor time.Duration) *Keyring {
+	return &Keyring{
+		store:       store,
+		rotateEvery: rotateEvery,
+		retainFor:   retainFor,
+	}
+}
+
+// Load reads persisted keys and rotates if there is no key young enough to
+// keep signing.
+func (k *Keyring) Load(ctx context.Context) error {
+	keys, err := k.store.SigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+
+	if len(keys) == 0 || time.Since(keys[0].CreatedAt) >= k.rotateEvery {
+		return k.Rotate(ctx)
+	}
+	return nil
+}
+
+func (k *Keyring) Rotate(ctx context.Context) error {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	kid, err := NewID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	key := model.SigningKey{
+		Kid:        kid[:16],
+		PrivateKey: priv,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(k.rotateEvery + k.retainFor),
+	}
+	if err := k.store.SaveSigningKey(ctx, key); err != nil {
+		return err
+	}
+	if err := k.store.DeleteExpiredSigningKeys(ctx); err != nil {
+		return err
+	}
+
+	keys, err := k.store.SigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+
+	slog.Info("rotated signing key", "kid", key.Kid)
+	return nil
+}
+
+// Run rotates keys on schedule until ctx is cancelled.
+func (k *Keyring) Run(ctx context.Context) {
+	ticker := time.NewTicker(k.rotateEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Rotate(ctx); err != nil {
+				slog.Error("cannot rotate signing key", "error", err)
+			}
+		}
+	}
+}
+
+func (k *Keyring) Current() (model.SigningKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if len(k.keys) == 0 {
+		return model.SigningKey{}, errors.New("keyring is empty")
+	}
+	return k.keys[0], nil
+}
+
+func (k *Keyring) PublicKey(kid string) (ed25519.PublicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.Kid == kid {
+			return key.PrivateKey.Public().(ed25519.PublicKey), true
+		}
+	}
+	return nil, false
+}
+
+func (k *Keyring) PublicKeys() []JWK {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	jwks := make([]JWK, 0, len(k.keys))
+	for _, key := range k.keys {
+		pub := key.PrivateKey.Public().(ed25519.PublicKey)
+		jwks = append(jwks, JWK{
+			Kid: key.Kid,
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+			Alg: "EdDSA",
+			Use: "sig",
+		})
+	}
+	return jwks
+}
@@ -0,0 +1,109 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+// testParams keep the tests fast; they are far below production strength.
+var testParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHashVerify(t *testing.T) {
+	a := NewArgon2id(testParams)
+
+	hash, err := a.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+	if !a.Owns(hash) {
+		t.Error("Owns(hash) = false, want true")
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"correct password", "correct horse", true},
+		{"wrong password", "battery staple", false},
+		{"empty password", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.Verify(hash, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	other, _ := a.Hash("correct horse")
+	if other == hash {
+		t.Error("two hashes of the same password share a salt")
+	}
+}
+
+func TestArgon2idVerifyMalformed(t *testing.T) {
+	a := NewArgon2id(testParams)
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"too few parts", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA"},
+		{"other scheme", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{"bad version", "$argon2id$v=x$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{"unsupported version", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{"bad params", "$argon2id$v=19$m=a,t=1,p=1$c2FsdA$a2V5"},
+		{"bad salt", "$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5"},
+		{"bad key", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$!!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.Verify(tt.encoded, "password"); err == nil {
+				t.Error("Verify() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	weak := NewArgon2id(testParams)
+	hash, err := weak.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := testParams
+	stronger.Iterations = 2
+
+	tests := []struct {
+		name    string
+		params  Argon2idParams
+		encoded string
+		want    bool
+	}{
+		{"same params", testParams, hash, false},
+		{"stronger params", stronger, hash, true},
+		{"malformed hash", testParams, "$argon2id$", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewArgon2id(tt.params).NeedsRehash(tt.encoded); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package password
+
+import "errors"
+
+var ErrUnknownScheme = errors.New("unknown password hash scheme")
+
+// Hasher is one password hashing scheme. Hashes are self-describing strings
+// in PHC format (or the native format of legacy schemes), so the parameters
+// a hash was made with travel with it.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) (bool, error)
+	// Owns reports whether encoded was produced by this scheme.
+	Owns(encoded string) bool
+	// NeedsRehash reports whether encoded was made with parameters weaker
+	// than the ones Hash uses now.
+	NeedsRehash(encoded string) bool
+}
+
+// Chain hashes new passwords with the current scheme and still verifies
+// hashes made by legacy ones.
+type Chain struct {
+	current Hasher
+	legacy  []Hasher
+}
+
+func NewChain(current Hasher, legacy ...Hasher) *Chain {
+	return &Chain{current: current, legacy: legacy}
+}
+
+func (c *Chain) Hash(password string) (string, error) {
+	return c.current.Hash(password)
+}
+
+// Verify checks password against encoded. rehash is set when the password
+// matched but encoded should be replaced by a fresh Hash.
+func (c *Chain) Verify(encoded, password string) (ok, rehash bool, err error) {
+	// Accounts created through an identity provider have no password.
+	if encoded == "" {
+		return false, false, nil
+	}
+
+	if c.current.Owns(encoded) {
+		ok, err = c.current.Verify(encoded, password)
+		return ok, ok && c.current.NeedsRehash(encoded), err
+	}
+
+	for _, h := range c.legacy {
+		if h.Owns(encoded) {
+			ok, err = h.Verify(encoded, password)
+			return ok, ok, err
+		}
+	}
+	return false, false, ErrUnknownScheme
+}
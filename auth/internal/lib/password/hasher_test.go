@@ -0,0 +1,58 @@
+package password
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChainVerify(t *testing.T) {
+	current := NewArgon2id(testParams)
+	legacy := NewBcrypt(bcrypt.MinCost)
+	chain := NewChain(current, legacy)
+
+	argonHash, err := current.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := legacy.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stronger := testParams
+	stronger.Memory = 2048
+	weakHash, err := current.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		chain      *Chain
+		encoded    string
+		password   string
+		wantOK     bool
+		wantRehash bool
+		wantErr    error
+	}{
+		{"current scheme", chain, argonHash, "secret", true, false, nil},
+		{"current scheme wrong password", chain, argonHash, "wrong", false, false, nil},
+		{"legacy scheme is rehashed", chain, bcryptHash, "secret", true, true, nil},
+		{"legacy scheme wrong password", chain, bcryptHash, "wrong", false, false, nil},
+		{"weaker params are rehashed", NewChain(NewArgon2id(stronger), legacy), weakHash, "secret", true, true, nil},
+		{"no password", chain, "", "secret", false, false, nil},
+		{"unknown scheme", chain, "$1$abc", "secret", false, false, ErrUnknownScheme},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := tt.chain.Verify(tt.encoded, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || rehash != tt.wantRehash {
+				t.Errorf("Verify() = (%v, %v), want (%v, %v)", ok, rehash, tt.wantOK, tt.wantRehash)
+			}
+		})
+	}
+}
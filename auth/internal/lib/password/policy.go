@@ -0,0 +1,78 @@
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation is one reason a password was refused, addressed to the request
+// field that carried it.
+type Violation struct {
+	Field       string
+	Description string
+}
+
+// Policy decides which passwords are acceptable.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	banned    map[string]struct{}
+}
+
+func NewPolicy(minLength, maxLength int) *Policy {
+	return &Policy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		banned:    map[string]struct{}{},
+	}
+}
+
+// LoadBanned reads a list of forbidden passwords, one per line. Blank lines
+// and lines starting with # are skipped; matching ignores case.
+func (p *Policy) LoadBanned(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.banned[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Check returns every rule the password breaks. field names the request
+// field in the violations; email, when given, must not be part of the
+// password.
+func (p *Policy) Check(field, password, email string) []Violation {
+	var violations []Violation
+	add := func(format string, args ...interface{}) {
+		violations = append(violations, Violation{Field: field, Description: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add("must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add("must be at most %d characters long", p.MaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := p.banned[lower]; ok {
+		add("is too common")
+	}
+	if local, _, _ := strings.Cut(strings.ToLower(email), "@"); len(local) >= 3 && strings.Contains(lower, local) {
+		add("must not contain the email address")
+	}
+	return violations
+}
@@ -0,0 +1,56 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	dir := t.TempDir()
+	banned := filepath.Join(dir, "banned.txt")
+	if err := os.WriteFile(banned, []byte("# common passwords\n\nPassword123\nqwertyuiop\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPolicy(8, 16)
+	if err := p.LoadBanned(banned); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		want     []string
+	}{
+		{"acceptable", "s3cure-enough", "alice@example.com", nil},
+		{"too short", "short", "", []string{"must be at least 8 characters long"}},
+		{"too long", "this-password-is-too-long", "", []string{"must be at most 16 characters long"}},
+		{"length counts runes", "пароль-ок", "", nil},
+		{"banned ignores case", "PASSWORD123", "", []string{"is too common"}},
+		{"contains email", "xxalice2024", "Alice@example.com", []string{"must not contain the email address"}},
+		{"short local part is ignored", "bob-password", "bo@example.com", nil},
+		{"several violations", "qwertyuiop", "qwerty@example.com", []string{"is too common", "must not contain the email address"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Check("password", tt.password, tt.email)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Check() = %v, want %v", got, tt.want)
+			}
+			for i, v := range got {
+				if v.Field != "password" || v.Description != tt.want[i] {
+					t.Errorf("violation %d = %+v, want %q on field password", i, v, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyLoadBannedMissingFile(t *testing.T) {
+	p := NewPolicy(8, 0)
+	if err := p.LoadBanned(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadBanned() error = nil, want an error")
+	}
+}
@@ -0,0 +1,81 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors.
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got := generate([]byte("12345678901234567890"), tt.unix/period)
+		if got != tt.want {
+			t.Errorf("generate(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / period
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfcSecret, "005924", step, true},
+		{"lowercase secret", strings.ToLower(rfcSecret), "005924", step, true},
+		{"previous step", rfcSecret, generate(key, step-1), step - 1, true},
+		{"next step", rfcSecret, generate(key, step+1), step + 1, true},
+		{"outside skew", rfcSecret, generate(key, step-2), 0, false},
+		{"wrong code", rfcSecret, "000000", 0, false},
+		{"short code", rfcSecret, "05924", 0, false},
+		{"invalid secret", "not base32!", "005924", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := Validate(tt.secret, tt.code, now)
+			if gotOK != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate() = (%d, %v), want (%d, %v)", gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret is not base32: %v", err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+
+	other, _ := NewSecret()
+	if other == secret {
+		t.Error("NewSecret returned the same secret twice")
+	}
+}
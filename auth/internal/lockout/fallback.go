@@ -0,0 +1,56 @@
+package lockout
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// FallbackStore uses primary and switches to secondary for any call where
+// primary fails, so lockouts keep working while Redis is down.
+type FallbackStore struct {
+	primary   Store
+	secondary Store
+}
+
+func NewFallbackStore(primary, secondary Store) *FallbackStore {
+	return &FallbackStore{
+		primary:   primary,
+		secondary: secondary,
+	}
+}
+
+func (s *FallbackStore) Get(ctx context.Context, key string) (Counter, error) {
+	c, err := s.primary.Get(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "login attempts primary store failed, using fallback", "error", err)
+		return s.secondary.Get(ctx, key)
+	}
+	return c, nil
+}
+
+func (s *FallbackStore) Incr(ctx context.Context, key string, ttl time.Duration) (Counter, error) {
+	c, err := s.primary.Incr(ctx, key, ttl)
+	if err != nil {
+		slog.WarnContext(ctx, "login attempts primary store failed, using fallback", "error", err)
+		return s.secondary.Incr(ctx, key, ttl)
+	}
+	return c, nil
+}
+
+func (s *FallbackStore) Lock(ctx context.Context, key string, until time.Time, ttl time.Duration) error {
+	if err := s.primary.Lock(ctx, key, until, ttl); err != nil {
+		slog.WarnContext(ctx, "login attempts primary store failed, using fallback", "error", err)
+		return s.secondary.Lock(ctx, key, until, ttl)
+	}
+	return nil
+}
+
+func (s *FallbackStore) Reset(ctx context.Context, key string) error {
+	perr := s.primary.Reset(ctx, key)
+	serr := s.secondary.Reset(ctx, key)
+	if perr != nil {
+		return serr
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package lockout
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Counter is the failed login state for one key (an email or a client IP).
+type Counter struct {
+	Failures    int
+	LockedUntil time.Time
+}
+
+// Store keeps counters. Incr and Lock must be atomic per key so that
+// concurrent failures are all counted and a lock is never shortened.
+type Store interface {
+	Get(ctx context.Context, key string) (Counter, error)
+	// Incr adds one failure to key, keeps it for at least ttl and returns
+	// the updated counter.
+	Incr(ctx context.Context, key string, ttl time.Duration) (Counter, error)
+	// Lock locks key until until, unless it is already locked for longer,
+	// and keeps it for at least ttl.
+	Lock(ctx context.Context, key string, until time.Time, ttl time.Duration) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Guard counts failed logins and locks keys out with exponential backoff
+// once they pass the threshold.
+type Guard struct {
+	store     Store
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	window    time.Duration
+}
+
+func NewGuard(store Store, threshold int, base, max, window time.Duration) *Guard {
+	return &Guard{
+		store:     store,
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		window:    window,
+	}
+}
+
+// Check returns how long the caller has to wait if any of the keys is
+// locked, or zero. Store errors are logged and do not block logins.
+func (g *Guard) Check(ctx context.Context, keys ...string) time.Duration {
+	var wait time.Duration
+	for _, key := range keys {
+		c, err := g.store.Get(ctx, key)
+		if err != nil {
+			slog.ErrorContext(ctx, "cannot read login attempts", "key", key, "error", err)
+			continue
+		}
+		if d := time.Until(c.LockedUntil); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Fail records a failed attempt for every key and returns the longest
+// lockout it caused.
+func (g *Guard) Fail(ctx context.Context, keys ...string) time.Duration {
+	var wait time.Duration
+	for _, key := range keys {
+		c, err := g.store.Incr(ctx, key, g.window)
+		if err != nil {
+			slog.ErrorContext(ctx, "cannot record failed login", "key", key, "error", err)
+			continue
+		}
+
+		lock := g.lockFor(c.Failures)
+		if lock == 0 {
+			continue
+		}
+		if err := g.store.Lock(ctx, key, time.Now().Add(lock), max(lock, g.window)); err != nil {
+			slog.ErrorContext(ctx, "cannot lock out login", "key", key, "error", err)
+			continue
+		}
+		if lock > wait {
+			wait = lock
+		}
+	}
+	return wait
+}
+
+// lockFor is the lockout earned by the given number of failures: none
+// below the threshold, then base doubling with every further failure, up
+// to max.
+func (g *Guard) lockFor(failures int) time.Duration {
+	over := failures - g.threshold
+	if over < 0 {
+		return 0
+	}
+	lock := time.Duration(float64(g.base) * math.Pow(2, float64(over)))
+	if lock > g.max || lock <= 0 {
+		lock = g.max
+	}
+	return lock
+}
+
+func (g *Guard) Reset(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := g.store.Reset(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "cannot reset login attempts", "key", key, "error", err)
+		}
+	}
+}
+
+// EmailKey counts failures per account. Emails are compared the way users
+// type them, so "Foo@x" and " foo@x" share a counter.
+func EmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// SecondFactorKey counts wrong TOTP and recovery codes of one user.
+func SecondFactorKey(userID int64) string {
+	return "2fa:" + strconv.FormatInt(userID, 10)
+}
@@ -0,0 +1,126 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory Store for tests.
+type memStore struct {
+	mu       sync.Mutex
+	counters map[string]Counter
+}
+
+func newMemStore() *memStore {
+	return &memStore{counters: make(map[string]Counter)}
+}
+
+func (s *memStore) Get(ctx context.Context, key string) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[key], nil
+}
+
+func (s *memStore) Incr(ctx context.Context, key string, ttl time.Duration) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counters[key]
+	c.Failures++
+	s.counters[key] = c
+	return c, nil
+}
+
+func (s *memStore) Lock(ctx context.Context, key string, until time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counters[key]
+	if until.After(c.LockedUntil) {
+		c.LockedUntil = until
+	}
+	s.counters[key] = c
+	return nil
+}
+
+func (s *memStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.counters, key)
+	return nil
+}
+
+func TestGuardFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{"below threshold", 2, 0},
+		{"at threshold", 3, time.Second},
+		{"one over", 4, 2 * time.Second},
+		{"two over", 5, 4 * time.Second},
+		{"capped at max", 10, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGuard(newMemStore(), 3, time.Second, 10*time.Second, time.Minute)
+			var got time.Duration
+			for i := 0; i < tt.failures; i++ {
+				got = g.Fail(context.Background(), "email:a@b.c")
+			}
+			if got != tt.want {
+				t.Fatalf("Fail after %d failures = %v, want %v", tt.failures, got, tt.want)
+			}
+			if wait := g.Check(context.Background(), "email:a@b.c"); (wait > 0) != (tt.want > 0) {
+				t.Fatalf("Check = %v, want locked %v", wait, tt.want > 0)
+			}
+		})
+	}
+}
+
+func TestGuardReset(t *testing.T) {
+	g := NewGuard(newMemStore(), 1, time.Minute, time.Hour, time.Minute)
+	ctx := context.Background()
+	email, ip := EmailKey("a@b.c"), IPKey("10.0.0.1")
+
+	g.Fail(ctx, email, ip)
+	g.Reset(ctx, email)
+
+	if wait := g.Check(ctx, email); wait != 0 {
+		t.Fatalf("email still locked for %v after reset", wait)
+	}
+	if wait := g.Check(ctx, ip); wait == 0 {
+		t.Fatal("resetting the email cleared the address lock")
+	}
+}
+
+func TestGuardFailConcurrent(t *testing.T) {
+	store := newMemStore()
+	g := NewGuard(store, 100, time.Second, time.Hour, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Fail(context.Background(), "ip:10.0.0.1")
+		}()
+	}
+	wg.Wait()
+
+	c, _ := store.Get(context.Background(), "ip:10.0.0.1")
+	if c.Failures != 50 {
+		t.Fatalf("counted %d failures, want 50", c.Failures)
+	}
+}
+
+func TestEmailKey(t *testing.T) {
+	want := EmailKey("foo@example.com")
+	for _, email := range []string{"Foo@Example.com", " foo@example.com\t", "FOO@EXAMPLE.COM"} {
+		if got := EmailKey(email); got != want {
+			t.Errorf("EmailKey(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (Counter, error) {
+	vals, err := s.client.HGetAll(ctx, redisKey(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return Counter{}, nil
+	}
+	if err != nil {
+		return Counter{}, err
+	}
+
+	var c Counter
+	c.Failures, _ = strconv.Atoi(vals["failures"])
+	if until, _ := strconv.ParseInt(vals["locked_until"], 10, 64); until > 0 {
+		c.LockedUntil = time.Unix(until, 0)
+	}
+	return c, nil
+}
+
+// incrScript bumps the failure count and extends the key's expiry, never
+// shortening it, in one step.
+var incrScript = redis.NewScript(`
+local failures = redis.call("HINCRBY", KEYS[1], "failures", 1)
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[1]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {failures, tonumber(redis.call("HGET", KEYS[1], "locked_until") or "0")}
+`)
+
+// lockScript moves locked_until forward, never back, and extends the key's
+// expiry, never shortening it.
+var lockScript = redis.NewScript(`
+local current = tonumber(redis.call("HGET", KEYS[1], "locked_until") or "0")
+if tonumber(ARGV[1]) > current then
+	redis.call("HSET", KEYS[1], "locked_until", ARGV[1])
+end
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+func (s *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (Counter, error) {
+	vals, err := incrScript.Run(ctx, s.client, []string{redisKey(key)}, ttl.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Counter{}, err
+	}
+
+	c := Counter{Failures: int(vals[0])}
+	if vals[1] > 0 {
+		c.LockedUntil = time.Unix(vals[1], 0)
+	}
+	return c, nil
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, until time.Time, ttl time.Duration) error {
+	return lockScript.Run(ctx, s.client, []string{redisKey(key)}, until.Unix(), ttl.Milliseconds()).Err()
+}
+
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, redisKey(key)).Err()
+}
+
+func redisKey(key string) string {
+	return "login_attempts:" + key
+}
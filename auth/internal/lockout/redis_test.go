@@ -0,0 +1,85 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client), mr
+}
+
+func TestRedisStoreIncrConcurrent(t *testing.T) {
+	s, _ := newTestRedisStore(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Incr(ctx, "ip:10.0.0.1", time.Minute); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c, err := s.Get(ctx, "ip:10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Failures != 20 {
+		t.Fatalf("counted %d failures, want 20", c.Failures)
+	}
+}
+
+func TestRedisStoreLock(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name  string
+		locks []time.Time
+		want  time.Time
+	}{
+		{"first lock", []time.Time{now.Add(time.Minute)}, now.Add(time.Minute)},
+		{"extends", []time.Time{now.Add(time.Minute), now.Add(time.Hour)}, now.Add(time.Hour)},
+		{"never shortens", []time.Time{now.Add(time.Hour), now.Add(time.Minute)}, now.Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mr := newTestRedisStore(t)
+			ctx := context.Background()
+
+			if _, err := s.Incr(ctx, "email:a@b.c", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			for _, until := range tt.locks {
+				if err := s.Lock(ctx, "email:a@b.c", until, time.Until(until)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := s.Get(ctx, "email:a@b.c")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.LockedUntil.Equal(tt.want) {
+				t.Fatalf("locked until %v, want %v", c.LockedUntil, tt.want)
+			}
+			if ttl := mr.TTL(redisKey("email:a@b.c")); ttl < time.Until(tt.want)-time.Second {
+				t.Fatalf("key expires in %v, before the lock ends", ttl)
+			}
+		})
+	}
+}
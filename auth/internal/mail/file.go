@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+)
+
+// FileSender appends messages to a file, or prints them to stdout when
+// path is empty. Meant for local development.
+type FileSender struct {
+	path string
+
+	mu sync.Mutex
+}
+
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+func (s *FileSender) Send(ctx context.Context, msg model.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var w io.Writer = os.Stdout
+	if s.path != "" {
+		f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	_, err := fmt.Fprintf(w, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.Recipient, msg.Subject, msg.Body)
+	return err
+}
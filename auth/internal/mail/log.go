@@ -0,0 +1,23 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+)
+
+// LogSender only logs messages. It is the default for local runs so reset
+// and verification links can be copied from the service output.
+type LogSender struct {
+	log *slog.Logger
+}
+
+func NewLogSender(log *slog.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg model.OutboxMessage) error {
+	s.log.Info("mail", "to", msg.Recipient, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
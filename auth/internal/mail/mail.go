@@ -0,0 +1,13 @@
+package mail
+
+import (
+	"context"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+)
+
+// Sender delivers a single message. Implementations must be safe to retry:
+// the outbox calls Send again for messages that failed.
+type Sender interface {
+	Send(ctx context.Context, msg model.OutboxMessage) error
+}
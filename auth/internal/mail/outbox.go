@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+)
+
+const (
+	batchSize   = 20
+	maxAttempts = 10
+)
+
+type OutboxStore interface {
+	PendingMail(ctx context.Context, limit int, maxAttempts int) ([]model.OutboxMessage, error)
+	MarkMailSent(ctx context.Context, id int64) error
+	MarkMailFailed(ctx context.Context, id int64, sendErr error) error
+}
+
+// Outbox periodically drains queued messages through a Sender. Messages
+// are written in the same database as the change that caused them, so a
+// mail server outage never loses a verification link.
+type Outbox struct {
+	store    OutboxStore
+	sender   Sender
+	interval time.Duration
+}
+
+func NewOutbox(store OutboxStore, sender Sender, interval time.Duration) *Outbox {
+	return &Outbox{
+		store:    store,
+		sender:   sender,
+		interval: interval,
+	}
+}
+
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		o.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Outbox) drain(ctx context.Context) {
+	msgs, err := o.store.PendingMail(ctx, batchSize, maxAttempts)
+	if err != nil {
+		slog.Error("cannot read outbox", "error", err)
+		return
+	}
+
+	for _, msg := range msgs {
+		if err := o.sender.Send(ctx, msg); err != nil {
+			slog.Warn("cannot send mail", "id", msg.ID, "attempt", msg.Attempts+1, "error", err)
+			if err := o.store.MarkMailFailed(ctx, msg.ID, err); err != nil {
+				slog.Error("cannot mark mail failed", "id", msg.ID, "error", err)
+			}
+			continue
+		}
+		if err := o.store.MarkMailSent(ctx, msg.ID); err != nil {
+			slog.Error("cannot mark mail sent", "id", msg.ID, "error", err)
+		}
+	}
+}
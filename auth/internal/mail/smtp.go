@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+)
+
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPSender sends through addr ("host:port"). Username may be empty for
+// relays that do not require authentication.
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPSender{
+		addr: addr,
+		from: from,
+		auth: auth,
+	}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg model.OutboxMessage) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.Recipient)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.Recipient}, []byte(b.String()))
+}
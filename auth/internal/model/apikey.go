@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Scopes an API key can be granted. Keys authenticate as RoleService and
+// may only call what their scopes allow.
+const (
+	ScopeInventoryRead  = "inventory:read"
+	ScopeInventoryWrite = "inventory:write"
+	ScopeOrdersRead     = "orders:read"
+	ScopeOrdersWrite    = "orders:write"
+)
+
+var Scopes = []string{ScopeInventoryRead, ScopeInventoryWrite, ScopeOrdersRead, ScopeOrdersWrite}
+
+// ServiceAccount is a non-human principal that owns API keys.
+type ServiceAccount struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+}
+
+// APIKey is a persisted API key. Prefix is stored in the clear to find the
+// key and to show it in listings; only the hash of the secret is kept.
+type APIKey struct {
+	ID               int64
+	ServiceAccountID int64
+	ServiceAccount   string
+	Prefix           string
+	SecretHash       string
+	Scopes           []string
+	CreatedAt        time.Time
+	LastUsedAt       time.Time
+	Revoked          bool
+}
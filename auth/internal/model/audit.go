@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// Audit event types.
+const (
+	EventRegister         = "register"
+	EventLoginSuccess     = "login_success"
+	EventLoginFailure     = "login_failure"
+	EventPasswordChange   = "password_change"
+	EventRoleChange       = "role_change"
+	EventTokenRevoked     = "token_revoked"
+	EventUserDisabled     = "user_disabled"
+	EventUserEnabled      = "user_enabled"
+	EventAPIKeyCreated    = "api_key_created"
+	EventAPIKeyRevoked    = "api_key_revoked"
+	EventAccountDeleted   = "account_deleted"
+	EventTwoFactorEnabled = "two_factor_enabled"
+)
+
+// AuditEvent is one security relevant thing that happened to an account.
+// UserID is the account affected, ActorID whoever caused it when that is
+// someone else (an admin).
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	UserID    int64     `json:"user_id,omitempty"`
+	ActorID   int64     `json:"actor_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Success   bool      `json:"success"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter narrows ListAuditEvents. Zero fields match everything.
+type AuditFilter struct {
+	UserID int64
+	Type   string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// OIDCState is a pending OpenID Connect login, keyed by the state parameter
+// sent to the provider.
+type OIDCState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// Identity links an account at an external provider to a local user.
+type Identity struct {
+	Provider  string
+	Subject   string
+	UserID    int64
+	Email     string
+	CreatedAt time.Time
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"crypto/ed25519"
+	"time"
+)
+
+// SigningKey is an Ed25519 key used to sign access tokens. Keys stay
+// published in the JWKS until ExpiresAt so tokens signed before a rotation
+// remain verifiable.
+type SigningKey struct {
+	Kid        string
+	PrivateKey ed25519.PrivateKey
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
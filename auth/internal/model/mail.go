@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// OutboxMessage is an email queued in the auth database until the mail
+// sender manages to deliver it.
+type OutboxMessage struct {
+	ID        int64
+	Recipient string
+	Subject   string
+	Body      string
+	Attempts  int
+	CreatedAt time.Time
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// PasswordReset is a single-use reset token. Only its hash is stored.
+type PasswordReset struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	Used      bool
+}
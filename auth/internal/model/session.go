@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Session is one login on one device. Its ID is the family id of the
+// refresh tokens minted for that login and the sid claim of its access
+// tokens.
+type Session struct {
+	ID         string
+	UserID     int64
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	Revoked    bool
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// RefreshToken is a persisted refresh token. Only the hash of the token is
+// stored; every token minted by rotating another one shares its FamilyID.
+// MFA records that the family was started with the second factor.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	FamilyID  string
+	MFA       bool
+	Used      bool
+	Revoked   bool
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -1,7 +1,33 @@
 package model
 
+import "time"
+
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+	// RoleService is held by API keys of service accounts, never by users.
+	RoleService = "service"
+)
+
+// Roles is every role an admin may assign.
+var Roles = []string{RoleCustomer, RoleStaff, RoleAdmin}
+
 type User struct {
-	ID       int64  `json:"id"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	ID       int64    `json:"id"`
+	Email    string   `json:"email"`
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+
+	EmailVerified bool `json:"email_verified"`
+	Disabled      bool `json:"disabled"`
+
+	TOTPSecret  string `json:"-"`
+	TOTPEnabled bool   `json:"totp_enabled"`
+
+	DisplayName       string    `json:"display_name"`
+	Phone             string    `json:"phone"`
+	PreferredCurrency string    `json:"preferred_currency"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
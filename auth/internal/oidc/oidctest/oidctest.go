@@ -0,0 +1,274 @@
+// Package oidctest implements a minimal OpenID Connect provider, used by
+// cmd/mock-oidc for local development and by tests of the login flow.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const codeTTL = time.Minute
+
+type grant struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	challenge     string
+	email         string
+	emailVerified bool
+	expiresAt     time.Time
+}
+
+// Provider is an OpenID Connect provider that signs in whoever submits an
+// email address to its authorization endpoint.
+type Provider struct {
+	issuer       string
+	publicURL    string
+	clientID     string
+	clientSecret string
+
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	kid    string
+	grants map[string]grant
+}
+
+// New returns a provider for issuer that accepts one client. publicURL is
+// the base URL browsers use, when it differs from the issuer.
+func New(issuer, publicURL, clientID, clientSecret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	if publicURL == "" {
+		publicURL = issuer
+	}
+
+	p := &Provider{
+		issuer:       issuer,
+		publicURL:    publicURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		kid:          randomString(8),
+		grants:       map[string]grant{},
+		mux:          http.NewServeMux(),
+	}
+	p.mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	p.mux.HandleFunc("GET /authorize", p.authorize)
+	p.mux.HandleFunc("POST /token", p.token)
+	p.mux.HandleFunc("GET /jwks", p.jwks)
+	return p, nil
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// RotateKey replaces the signing key, as providers do periodically. Tokens
+// signed before keep their old kid, which the JWKS no longer lists.
+func (p *Provider) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.key = key
+	p.kid = randomString(8)
+	return nil
+}
+
+var loginForm = template.Must(template.New("login").Parse(`<!doctype html>
+<title>mock-oidc</title>
+<h1>mock-oidc sign in</h1>
+<form method="get" action="/authorize">
+  {{range $k, $v := .}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+  {{end}}
+  <label>Email <input name="email" type="email" required></label><br>
+  <label><input name="email_verified" type="checkbox" value="true" checked> email verified</label><br>
+  <button type="submit">Sign in</button>
+</form>
+`))
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.publicURL + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize shows the login form and, once it is submitted, redirects back
+// to the client with a one-time code.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != p.clientID || q.Get("response_type") != "code" || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "S256 code_challenge is required", http.StatusBadRequest)
+		return
+	}
+
+	if q.Get("email") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginForm.Execute(w, q)
+		return
+	}
+
+	code := randomString(16)
+	p.mu.Lock()
+	p.grants[code] = grant{
+		clientID:      q.Get("client_id"),
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		challenge:     q.Get("code_challenge"),
+		email:         q.Get("email"),
+		emailVerified: q.Get("email_verified") == "true",
+		expiresAt:     time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, "invalid_request", err.Error())
+		return
+	}
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	clientID, _ = url.QueryUnescape(clientID)
+	secret, _ = url.QueryUnescape(secret)
+	if clientID != p.clientID || subtle.ConstantTimeCompare([]byte(secret), []byte(p.clientSecret)) != 1 {
+		oauthError(w, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		oauthError(w, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+
+	switch {
+	case !ok || time.Now().After(g.expiresAt) || g.clientID != clientID:
+		oauthError(w, "invalid_grant", "unknown or expired code")
+		return
+	case r.PostForm.Get("redirect_uri") != g.redirectURI:
+		oauthError(w, "invalid_grant", "redirect_uri mismatch")
+		return
+	case challenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		oauthError(w, "invalid_grant", "code_verifier does not match code_challenge")
+		return
+	}
+
+	sum := sha256.Sum256([]byte(g.email))
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.issuer,
+		"sub":            hex.EncodeToString(sum[:8]),
+		"aud":            clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          g.nonce,
+		"email":          g.email,
+		"email_verified": g.emailVerified,
+	})
+	p.mu.Lock()
+	key, kid := p.key, p.kid
+	p.mu.Unlock()
+	idToken.Header["kid"] = kid
+
+	signed, err := idToken.SignedString(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(16),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	pub, kid := p.key.PublicKey, p.kid
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": kid,
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func oauthError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,29 @@
+package oidc
+
+import "testing"
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636, appendix B.
+	const (
+		verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		want     = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+	if got := Challenge(verifier); got != want {
+		t.Errorf("Challenge() = %s, want %s", got, want)
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	v, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 32 random bytes are 43 characters of base64url, the minimum length
+	// RFC 7636 allows.
+	if len(v) != 43 {
+		t.Errorf("verifier has %d characters, want 43", len(v))
+	}
+	if other, _ := NewVerifier(); other == v {
+		t.Error("NewVerifier returned the same verifier twice")
+	}
+}
@@ -0,0 +1,204 @@
+// Package oidc implements the relying party side of the OpenID Connect
+// authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnknownProvider = errors.New("unknown oidc provider")
+
+const (
+	// fetchTimeout bounds every request to the provider, whatever the
+	// deadline of the calling RPC.
+	fetchTimeout = 10 * time.Second
+	// metadataTTL is how long a discovery document is used before it is
+	// fetched again.
+	metadataTTL = time.Hour
+)
+
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// metadata is the part of the discovery document the flow needs.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is one configured identity provider. Its discovery document and
+// signing keys are fetched on first use, so the provider does not have to
+// be reachable when auth starts.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu          sync.Mutex
+	meta        *metadata
+	metaFetched time.Time
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+func NewProvider(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthURL returns the URL the browser is sent to in order to sign in.
+func (p *Provider) AuthURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades an authorization code for the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	var res struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.do(req, &res); err != nil {
+		if res.Error != "" {
+			return "", fmt.Errorf("token endpoint: %s: %s", res.Error, res.ErrorDescription)
+		}
+		return "", err
+	}
+	if res.IDToken == "" {
+		return "", errors.New("token endpoint returned no id_token")
+	}
+	return res.IDToken, nil
+}
+
+// discover returns the provider's discovery document, fetching it again
+// once it is older than metadataTTL. The fetch runs without holding p.mu,
+// and a stale document keeps being used while the provider is unreachable.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	meta, fetched := p.meta, p.metaFetched
+	p.mu.Unlock()
+
+	if meta != nil && time.Since(fetched) < metadataTTL {
+		return meta, nil
+	}
+
+	fresh, err := p.fetchMetadata(ctx)
+	if err != nil {
+		if meta != nil {
+			return meta, nil
+		}
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.meta = fresh
+	p.metaFetched = time.Now()
+	p.mu.Unlock()
+
+	return fresh, nil
+}
+
+func (p *Provider) fetchMetadata(ctx context.Context) (*metadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta metadata
+	if err := p.do(req, &meta); err != nil {
+		return nil, fmt.Errorf("discover %s: %w", p.cfg.Name, err)
+	}
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discover %s: issuer %q does not match %q", p.cfg.Name, meta.Issuer, p.cfg.Issuer)
+	}
+	return &meta, nil
+}
+
+// do sends req and decodes the JSON body into v. The body is decoded even
+// for error statuses so callers can read OAuth error fields.
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(body, v)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, res.Status)
+	}
+	return decodeErr
+}
@@ -0,0 +1,282 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/oidc/oidctest"
+)
+
+const (
+	testClientID     = "comics-store"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost:8080/auth/oidc/mock/callback"
+)
+
+// testEnv runs the mock provider and counts discovery requests to it.
+type testEnv struct {
+	srv       *httptest.Server
+	mock      *oidctest.Provider
+	discovery atomic.Int32
+	down      atomic.Bool
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	env := &testEnv{}
+	env.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if env.down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/.well-known/openid-configuration" {
+			env.discovery.Add(1)
+		}
+		env.mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(env.srv.Close)
+
+	mock, err := oidctest.New(env.srv.URL, "", testClientID, testClientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.mock = mock
+	return env
+}
+
+func (env *testEnv) provider() *Provider {
+	return NewProvider(Config{
+		Name:         "mock",
+		Issuer:       env.srv.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	})
+}
+
+// signIn follows authURL as a browser would after the user typed email into
+// the provider's form, and returns the code and state of the callback.
+func signIn(t *testing.T, authURL, email string, verified bool) (code, state string) {
+	t.Helper()
+
+	q := url.Values{"email": {email}}
+	if verified {
+		q.Set("email_verified", "true")
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL + "&" + q.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize answered %s", res.Status)
+	}
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func TestLoginFlow(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		verified     bool
+		exchangeWith func(verifier string) string
+		verifyNonce  func(nonce string) string
+		wantExchange bool
+		wantVerify   bool
+	}{
+		{
+			name:         "verified email",
+			verified:     true,
+			wantExchange: true,
+			wantVerify:   true,
+		},
+		{
+			name:         "unverified email",
+			wantExchange: true,
+			wantVerify:   true,
+		},
+		{
+			name:         "wrong code verifier",
+			verified:     true,
+			exchangeWith: func(string) string { v, _ := NewVerifier(); return v },
+		},
+		{
+			name:         "wrong nonce",
+			verified:     true,
+			verifyNonce:  func(nonce string) string { return nonce + "x" },
+			wantExchange: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := env.provider()
+			verifier, err := NewVerifier()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authURL, err := p.AuthURL(ctx, "state-1", "nonce-1", verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, state := signIn(t, authURL, "alice@example.com", tt.verified)
+			if state != "state-1" {
+				t.Errorf("callback state = %q, want state-1", state)
+			}
+
+			if tt.exchangeWith != nil {
+				verifier = tt.exchangeWith(verifier)
+			}
+			rawIDToken, err := p.Exchange(ctx, code, verifier)
+			if (err == nil) != tt.wantExchange {
+				t.Fatalf("Exchange() error = %v, want success %v", err, tt.wantExchange)
+			}
+			if err != nil {
+				return
+			}
+
+			nonce := "nonce-1"
+			if tt.verifyNonce != nil {
+				nonce = tt.verifyNonce(nonce)
+			}
+			id, err := p.Verify(ctx, rawIDToken, nonce)
+			if (err == nil) != tt.wantVerify {
+				t.Fatalf("Verify() error = %v, want success %v", err, tt.wantVerify)
+			}
+			if err != nil {
+				return
+			}
+			if id.Subject == "" || id.Email != "alice@example.com" || id.EmailVerified != tt.verified {
+				t.Errorf("Verify() = %+v", id)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	p := env.provider()
+	ctx := context.Background()
+
+	verifier, _ := NewVerifier()
+	authURL, err := p.AuthURL(ctx, "state", "nonce", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := signIn(t, authURL, "bob@example.com", true)
+
+	if _, err := p.Exchange(ctx, code, verifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Exchange(ctx, code, verifier); err == nil {
+		t.Error("second Exchange() of the same code succeeded")
+	}
+}
+
+func TestVerifyPicksUpRotatedKey(t *testing.T) {
+	env := newTestEnv(t)
+	p := env.provider()
+	ctx := context.Background()
+
+	login := func() error {
+		verifier, _ := NewVerifier()
+		authURL, err := p.AuthURL(ctx, "state", "nonce", verifier)
+		if err != nil {
+			return err
+		}
+		code, _ := signIn(t, authURL, "carol@example.com", true)
+		rawIDToken, err := p.Exchange(ctx, code, verifier)
+		if err != nil {
+			return err
+		}
+		_, err = p.Verify(ctx, rawIDToken, "nonce")
+		return err
+	}
+
+	if err := login(); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.mock.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Right after a fetch an unknown kid does not trigger another one.
+	if err := login(); err == nil {
+		t.Fatal("Verify() accepted a kid without refetching the JWKS")
+	}
+
+	p.mu.Lock()
+	p.keysFetched = p.keysFetched.Add(-keysMinRefresh)
+	p.mu.Unlock()
+	if err := login(); err != nil {
+		t.Fatalf("Verify() after rotation: %v", err)
+	}
+}
+
+func TestDiscoverCachesMetadata(t *testing.T) {
+	env := newTestEnv(t)
+	p := env.provider()
+	ctx := context.Background()
+
+	expire := func() {
+		p.mu.Lock()
+		p.metaFetched = p.metaFetched.Add(-metadataTTL)
+		p.mu.Unlock()
+	}
+
+	steps := []struct {
+		name          string
+		before        func()
+		wantErr       bool
+		wantDiscovery int32
+	}{
+		{"first use fetches", nil, false, 1},
+		{"fresh metadata is cached", nil, false, 1},
+		{"expired metadata is refetched", expire, false, 2},
+		{"stale metadata is used while the provider is down", func() { expire(); env.down.Store(true) }, false, 2},
+	}
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		_, err := p.discover(ctx)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: discover() error = %v", step.name, err)
+		}
+		if got := env.discovery.Load(); got != step.wantDiscovery {
+			t.Errorf("%s: %d discovery requests, want %d", step.name, got, step.wantDiscovery)
+		}
+	}
+
+	if _, err := env.provider().discover(ctx); err == nil {
+		t.Error("discover() without cached metadata succeeded while the provider is down")
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	env := newTestEnv(t)
+	p := NewProvider(Config{Name: "mock", Issuer: env.srv.URL + "/", ClientID: testClientID})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := p.discover(ctx); err == nil {
+		t.Error("discover() accepted a document for another issuer")
+	}
+}
@@ -0,0 +1,147 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// keysMinRefresh limits how often an unknown kid may trigger a JWKS fetch.
+const keysMinRefresh = 30 * time.Second
+
+// Identity is what auth learns about a user from a verified ID token.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Verify checks the signature of an RS256 ID token against the provider's
+// JWKS and validates its issuer, audience, expiry and nonce.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (Identity, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, meta.JWKSURI, kid)
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify id token: %w", err)
+	}
+
+	if !claims.VerifyIssuer(p.cfg.Issuer, true) {
+		return Identity{}, errors.New("id token has wrong issuer")
+	}
+	if !claims.VerifyAudience(p.cfg.ClientID, true) {
+		return Identity{}, errors.New("id token has wrong audience")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Identity{}, errors.New("id token expired")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return Identity{}, errors.New("id token nonce mismatch")
+	}
+
+	id := Identity{}
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		id.EmailVerified = v
+	case string:
+		id.EmailVerified = v == "true"
+	}
+	if id.Subject == "" {
+		return Identity{}, errors.New("id token has no subject")
+	}
+	return id, nil
+}
+
+// key returns the signing key kid. A kid that is not cached triggers a new
+// JWKS fetch, so keys the provider rotated in are picked up, but at most
+// once per keysMinRefresh.
+func (p *Provider) key(ctx context.Context, jwksURI, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	stale := time.Since(p.keysFetched) >= keysMinRefresh
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx, jwksURI)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = time.Now()
+	p.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) (map[string]*rsa.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.do(req, &set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
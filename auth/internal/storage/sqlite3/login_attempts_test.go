@@ -0,0 +1,104 @@
+package sqlite1488
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/migrations"
+	"github.com/barcek2281/comics-store/pkg/migrator"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "user.db")
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.New(db, migrations.FS).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewStorage(path + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestLoginAttemptsIncrConcurrent(t *testing.T) {
+	a := newTestStorage(t).LoginAttempts()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := a.Incr(ctx, "ip:10.0.0.1", time.Minute); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c, err := a.Get(ctx, "ip:10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Failures != 20 {
+		t.Fatalf("counted %d failures, want 20", c.Failures)
+	}
+}
+
+func TestLoginAttemptsIncrExpired(t *testing.T) {
+	s := newTestStorage(t)
+	a := s.LoginAttempts()
+	ctx := context.Background()
+
+	_, err := s.db.Exec(
+		"INSERT INTO login_attempts(key, failures, locked_until, expires_at) VALUES (?, ?, ?, ?)",
+		"email:a@b.c", 7, time.Now().Add(-time.Hour).Unix(), time.Now().Add(-time.Minute).Unix(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := a.Incr(ctx, "email:a@b.c", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Failures != 1 || !c.LockedUntil.IsZero() {
+		t.Fatalf("expired row was not reset: %+v", c)
+	}
+}
+
+func TestLoginAttemptsLockNeverShortens(t *testing.T) {
+	a := newTestStorage(t).LoginAttempts()
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	if _, err := a.Incr(ctx, "email:a@b.c", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for _, until := range []time.Time{now.Add(time.Hour), now.Add(time.Minute)} {
+		if err := a.Lock(ctx, "email:a@b.c", until, time.Until(until)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := a.Get(ctx, "email:a@b.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.LockedUntil.Equal(now.Add(time.Hour)) {
+		t.Fatalf("locked until %v, want %v", c.LockedUntil, now.Add(time.Hour))
+	}
+}
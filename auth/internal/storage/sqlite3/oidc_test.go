@@ -0,0 +1,48 @@
+package sqlite1488
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+)
+
+func TestConsumeOIDCState(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	saved := []model.OIDCState{
+		{State: "live", Provider: "mock", Nonce: "n1", CodeVerifier: "v1", ExpiresAt: time.Now().Add(time.Minute)},
+		{State: "expired", Provider: "mock", Nonce: "n2", CodeVerifier: "v2", ExpiresAt: time.Now().Add(-time.Minute)},
+	}
+	for _, st := range saved {
+		if err := s.SaveOIDCState(ctx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		state   string
+		wantErr error
+	}{
+		{"live state", "live", nil},
+		{"state is single-use", "live", storage.ErrTokenNotFound},
+		{"expired state", "expired", storage.ErrTokenNotFound},
+		{"unknown state", "unknown", storage.ErrTokenNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ConsumeOIDCState(ctx, tt.state)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConsumeOIDCState() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Nonce != "n1" || got.CodeVerifier != "v1" || got.Provider != "mock") {
+				t.Errorf("ConsumeOIDCState() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package sqlite1488
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/barcek2281/comics-store/auth/internal/model"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	uid, err := s.Save(model.User{Email: "reader@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := s.SaveSession(ctx, model.Session{ID: "family", UserID: uid, CreatedAt: now, LastSeenAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []string{"first", "second"} {
+		err := s.SaveRefreshToken(ctx, model.RefreshToken{
+			UserID: uid, TokenHash: hash, FamilyID: "family", ExpiresAt: now.Add(time.Hour), CreatedAt: now,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := s.RefreshToken(ctx, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Used || first.Revoked || first.UserID != uid || first.FamilyID != "family" {
+		t.Fatalf("RefreshToken() = %+v, want a live token of the family", first)
+	}
+	if _, err := s.RefreshToken(ctx, "unknown"); !errors.Is(err, storage.ErrTokenNotFound) {
+		t.Errorf("RefreshToken(unknown) error = %v, want ErrTokenNotFound", err)
+	}
+
+	if err := s.UseRefreshToken(ctx, first.ID); err != nil {
+		t.Fatalf("first UseRefreshToken() = %v", err)
+	}
+	if err := s.UseRefreshToken(ctx, first.ID); !errors.Is(err, storage.ErrTokenUsed) {
+		t.Fatalf("second UseRefreshToken() = %v, want ErrTokenUsed", err)
+	}
+	if first, _ = s.RefreshToken(ctx, "first"); !first.Used {
+		t.Error("rotated token is not marked used")
+	}
+
+	if err := s.RevokeTokenFamily(ctx, "family"); err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.RefreshToken(ctx, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.Revoked {
+		t.Error("RevokeTokenFamily() left a token of the family live")
+	}
+	sessions, err := s.Sessions(ctx, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("Sessions() = %+v after RevokeTokenFamily, want none", sessions)
+	}
+}
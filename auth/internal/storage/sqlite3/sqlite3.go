@@ -2,11 +2,17 @@ package sqlite1488
 
 import (
 	"context"
+	"crypto/ed25519"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/barcek2281/comics-store/auth/internal/lockout"
 	"github.com/barcek2281/comics-store/auth/internal/model"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/barcek2281/comics-store/auth/internal/storage"
+	"github.com/mattn/go-sqlite3"
 )
 
 type Storage struct {
@@ -24,15 +30,33 @@ func NewStorage(storagePath string) (*Storage, error) {
 	return &Storage{db: db}, nil
 }
 
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
 func (s *Storage) Save(user model.User) (int64, error) {
 
-	stmt, err := s.db.Prepare("INSERT INTO users(email, password) VALUES(?, ?)")
+	stmt, err := s.db.Prepare(`INSERT INTO users(email, password, roles, preferred_currency, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return 0, err
 	}
 
-	res, err := stmt.Exec(user.Email, user.Password)
+	roles := user.Roles
+	if len(roles) == 0 {
+		roles = []string{model.RoleCustomer}
+	}
+	currency := user.PreferredCurrency
+	if currency == "" {
+		currency = "USD"
+	}
+	now := time.Now().Unix()
+
+	res, err := stmt.Exec(user.Email, user.Password, strings.Join(roles, ","), currency, now, now)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, storage.ErrUserExists
+		}
 		return 0, err
 	}
 
@@ -44,19 +68,918 @@ func (s *Storage) Save(user model.User) (int64, error) {
 	return id, nil
 }
 
-func (s *Storage) User(ctx context.Context, email string) (model.User, error) {
-	stmt, err := s.db.Prepare("SELECT id, email, password FROM users WHERE email = ?")
-	if err != nil {
-		return model.User{}, err
-	}
+const userColumns = `id, email, password, roles, email_verified, disabled, totp_secret, totp_enabled,
+	display_name, phone, preferred_currency, created_at, updated_at`
 
-	row := stmt.QueryRowContext(ctx, email)
+type scanner interface {
+	Scan(dest ...any) error
+}
 
+func scanUser(row scanner) (model.User, error) {
 	var user model.User
-	err = row.Scan(&user.ID, &user.Email, &user.Password)
+	var roles string
+	var createdAt, updatedAt int64
+	err := row.Scan(
+		&user.ID, &user.Email, &user.Password, &roles, &user.EmailVerified, &user.Disabled, &user.TOTPSecret, &user.TOTPEnabled,
+		&user.DisplayName, &user.Phone, &user.PreferredCurrency, &createdAt, &updatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, storage.ErrUserNotFound
+	}
 	if err != nil {
 		return model.User{}, err
 	}
+	user.Roles = strings.Split(roles, ",")
+	user.CreatedAt = time.Unix(createdAt, 0)
+	user.UpdatedAt = time.Unix(updatedAt, 0)
 
 	return user, nil
 }
+
+func (s *Storage) User(ctx context.Context, email string) (model.User, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email = ?", email)
+	return scanUser(row)
+}
+
+func (s *Storage) UserByID(ctx context.Context, id int64) (model.User, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", id)
+	return scanUser(row)
+}
+
+// ListUsers returns one page of users whose email contains query, ordered
+// by id, together with the total number of matches.
+func (s *Storage) ListUsers(ctx context.Context, query string, limit, offset int) ([]model.User, int, error) {
+	pattern := "%" + escapeLike(query) + "%"
+
+	var total int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM users WHERE email LIKE ? ESCAPE '\'`,
+		pattern,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+userColumns+` FROM users WHERE email LIKE ? ESCAPE '\' ORDER BY id LIMIT ? OFFSET ?`,
+		pattern, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+func (s *Storage) SetRoles(ctx context.Context, id int64, roles []string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users SET roles = ?, updated_at = ? WHERE id = ?",
+		strings.Join(roles, ","), time.Now().Unix(), id,
+	)
+	return err
+}
+
+func (s *Storage) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users SET disabled = ?, updated_at = ? WHERE id = ?",
+		disabled, time.Now().Unix(), id,
+	)
+	return err
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET password = ?, updated_at = ? WHERE id = ?", hash, time.Now().Unix(), id)
+	return err
+}
+
+func (s *Storage) UpdateProfile(ctx context.Context, user model.User) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users SET display_name = ?, phone = ?, preferred_currency = ?, updated_at = ? WHERE id = ?",
+		user.DisplayName, user.Phone, user.PreferredCurrency, time.Now().Unix(), user.ID,
+	)
+	return err
+}
+
+// ChangeEmail sets a new email address the user has confirmed.
+func (s *Storage) ChangeEmail(ctx context.Context, id int64, email string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users SET email = ?, email_verified = 1, updated_at = ? WHERE id = ?",
+		email, time.Now().Unix(), id,
+	)
+	if isUniqueViolation(err) {
+		return storage.ErrUserExists
+	}
+	return err
+}
+
+// DeleteUser removes the user together with everything that references it.
+func (s *Storage) DeleteUser(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{
+		"DELETE FROM refresh_tokens WHERE user_id = ?",
+		"DELETE FROM password_resets WHERE user_id = ?",
+		"DELETE FROM recovery_codes WHERE user_id = ?",
+		"DELETE FROM sessions WHERE user_id = ?",
+		"DELETE FROM user_identities WHERE user_id = ?",
+		"DELETE FROM users WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RevokeUserTokens revokes every refresh token of the user and marks all
+// access tokens issued before now as revoked. The moment is kept in
+// milliseconds, like the iat of access tokens, so a token issued in the
+// same second but before the revocation is still caught.
+func (s *Storage) RevokeUserTokens(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = 1 WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked = 1 WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET tokens_revoked_at = ? WHERE id = ?", time.Now().UnixMilli(), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TokensRevokedAt returns the moment before which all access tokens of the
+// user are considered revoked.
+func (s *Storage) TokensRevokedAt(ctx context.Context, id int64) (time.Time, error) {
+	var at int64
+	err := s.db.QueryRowContext(ctx, "SELECT tokens_revoked_at FROM users WHERE id = ?", id).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Rows revoked before milliseconds were stored hold seconds, which
+	// stay below 1e12 for the next thirty thousand years.
+	if at < 1e12 {
+		return time.Unix(at, 0), nil
+	}
+	return time.UnixMilli(at), nil
+}
+
+func (s *Storage) SetEmailVerified(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET email_verified = 1 WHERE id = ?", id)
+	return err
+}
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, token model.RefreshToken) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens(user_id, token_hash, family_id, mfa, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		token.UserID, token.TokenHash, token.FamilyID, token.MFA, token.ExpiresAt.Unix(), token.CreatedAt.Unix(),
+	)
+	return err
+}
+
+func (s *Storage) RefreshToken(ctx context.Context, tokenHash string) (model.RefreshToken, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, token_hash, family_id, mfa, used, revoked, expires_at, created_at
+		 FROM refresh_tokens WHERE token_hash = ?`,
+		tokenHash,
+	)
+
+	var token model.RefreshToken
+	var expiresAt, createdAt int64
+	err := row.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.FamilyID, &token.MFA, &token.Used, &token.Revoked, &expiresAt, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.RefreshToken{}, storage.ErrTokenNotFound
+	}
+	if err != nil {
+		return model.RefreshToken{}, err
+	}
+	token.ExpiresAt = time.Unix(expiresAt, 0)
+	token.CreatedAt = time.Unix(createdAt, 0)
+
+	return token, nil
+}
+
+// UseRefreshToken marks a refresh token as consumed. It returns
+// storage.ErrTokenUsed if another request already consumed it.
+func (s *Storage) UseRefreshToken(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET used = 1 WHERE id = ? AND used = 0", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrTokenUsed
+	}
+	return nil
+}
+
+// RevokeTokenFamily revokes every refresh token of the family and ends the
+// session it belongs to.
+func (s *Storage) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked = 1 WHERE family_id = ?", familyID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked = 1 WHERE id = ?", familyID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) SaveSession(ctx context.Context, session model.Session) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO sessions(id, user_id, user_agent, ip, created_at, last_seen_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.UserAgent, session.IP,
+		session.CreatedAt.Unix(), session.LastSeenAt.Unix(),
+	)
+	return err
+}
+
+// Sessions returns the live sessions of the user, most recently used first.
+func (s *Storage) Sessions(ctx context.Context, userID int64) ([]model.Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, user_id, user_agent, ip, created_at, last_seen_at, revoked
+		 FROM sessions WHERE user_id = ? AND revoked = 0 ORDER BY last_seen_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		var (
+			session           model.Session
+			createdAt, seenAt int64
+		)
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &createdAt, &seenAt, &session.Revoked)
+		if err != nil {
+			return nil, err
+		}
+		session.CreatedAt = time.Unix(createdAt, 0)
+		session.LastSeenAt = time.Unix(seenAt, 0)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records that the session was just used from ip.
+func (s *Storage) TouchSession(ctx context.Context, id, ip string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET last_seen_at = ?, ip = COALESCE(NULLIF(?, ''), ip) WHERE id = ?",
+		time.Now().Unix(), ip, id,
+	)
+	return err
+}
+
+// IsSessionRevoked reports whether the session was ended. Unknown sessions
+// count as revoked.
+func (s *Storage) IsSessionRevoked(ctx context.Context, id string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, "SELECT revoked FROM sessions WHERE id = ?", id).Scan(&revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// RevokeSession ends one session of the user. It returns ErrTokenNotFound
+// when the session does not exist or belongs to someone else.
+func (s *Storage) RevokeSession(ctx context.Context, userID int64, id string) error {
+	var owner int64
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM sessions WHERE id = ?", id).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && owner != userID) {
+		return storage.ErrTokenNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.RevokeTokenFamily(ctx, id)
+}
+
+func (s *Storage) SaveSigningKey(ctx context.Context, key model.SigningKey) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO signing_keys(kid, private_key, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		key.Kid, []byte(key.PrivateKey.Seed()), key.CreatedAt.Unix(), key.ExpiresAt.Unix(),
+	)
+	return err
+}
+
+// SigningKeys returns the keys that have not expired yet, newest first.
+func (s *Storage) SigningKeys(ctx context.Context) ([]model.SigningKey, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT kid, private_key, created_at, expires_at FROM signing_keys WHERE expires_at > ? ORDER BY created_at DESC",
+		time.Now().Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.SigningKey
+	for rows.Next() {
+		var key model.SigningKey
+		var seed []byte
+		var createdAt, expiresAt int64
+		if err := rows.Scan(&key.Kid, &seed, &createdAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("signing key %s: bad seed length %d", key.Kid, len(seed))
+		}
+		key.PrivateKey = ed25519.NewKeyFromSeed(seed)
+		key.CreatedAt = time.Unix(createdAt, 0)
+		key.ExpiresAt = time.Unix(expiresAt, 0)
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *Storage) DeleteExpiredSigningKeys(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM signing_keys WHERE expires_at <= ?", time.Now().Unix())
+	return err
+}
+
+func (s *Storage) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR IGNORE INTO revoked_tokens(jti, expires_at) VALUES (?, ?)",
+		jti, expiresAt.Unix(),
+	)
+	return err
+}
+
+// ConsumeTokenID marks a single-use token as spent by recording its jti
+// until expiresAt. It returns ErrTokenUsed if the jti was already spent.
+func (s *Storage) ConsumeTokenID(ctx context.Context, jti string, expiresAt time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		"INSERT OR IGNORE INTO revoked_tokens(jti, expires_at) VALUES (?, ?)",
+		jti, expiresAt.Unix(),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrTokenUsed
+	}
+	return nil
+}
+
+func (s *Storage) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?", jti).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// LoginAttempts implements lockout.Store on top of the login_attempts
+// table. Rows past their ttl are treated as absent.
+type LoginAttempts struct {
+	db *sql.DB
+}
+
+func (s *Storage) LoginAttempts() *LoginAttempts {
+	return &LoginAttempts{db: s.db}
+}
+
+func (a *LoginAttempts) Get(ctx context.Context, key string) (lockout.Counter, error) {
+	var failures int
+	var lockedUntil, expiresAt int64
+	err := a.db.QueryRowContext(ctx,
+		"SELECT failures, locked_until, expires_at FROM login_attempts WHERE key = ?",
+		key,
+	).Scan(&failures, &lockedUntil, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return lockout.Counter{}, nil
+	}
+	if err != nil {
+		return lockout.Counter{}, err
+	}
+	if time.Now().Unix() > expiresAt {
+		return lockout.Counter{}, nil
+	}
+
+	c := lockout.Counter{Failures: failures}
+	if lockedUntil > 0 {
+		c.LockedUntil = time.Unix(lockedUntil, 0)
+	}
+	return c, nil
+}
+
+// Incr counts a failure in a single statement, starting over when the
+// stored row has expired.
+func (a *LoginAttempts) Incr(ctx context.Context, key string, ttl time.Duration) (lockout.Counter, error) {
+	now := time.Now().Unix()
+	var failures int
+	var lockedUntil int64
+	err := a.db.QueryRowContext(ctx,
+		`INSERT INTO login_attempts(key, failures, locked_until, expires_at) VALUES (?, 1, 0, ?)
+		 ON CONFLICT(key) DO UPDATE SET
+		   failures = CASE WHEN expires_at < ? THEN 1 ELSE failures + 1 END,
+		   locked_until = CASE WHEN expires_at < ? THEN 0 ELSE locked_until END,
+		   expires_at = MAX(CASE WHEN expires_at < ? THEN 0 ELSE expires_at END, excluded.expires_at)
+		 RETURNING failures, locked_until`,
+		key, time.Now().Add(ttl).Unix(), now, now, now,
+	).Scan(&failures, &lockedUntil)
+	if err != nil {
+		return lockout.Counter{}, err
+	}
+
+	c := lockout.Counter{Failures: failures}
+	if lockedUntil > 0 {
+		c.LockedUntil = time.Unix(lockedUntil, 0)
+	}
+	return c, nil
+}
+
+func (a *LoginAttempts) Lock(ctx context.Context, key string, until time.Time, ttl time.Duration) error {
+	_, err := a.db.ExecContext(ctx,
+		`UPDATE login_attempts SET locked_until = MAX(locked_until, ?), expires_at = MAX(expires_at, ?)
+		 WHERE key = ?`,
+		until.Unix(), time.Now().Add(ttl).Unix(), key,
+	)
+	return err
+}
+
+func (a *LoginAttempts) Reset(ctx context.Context, key string) error {
+	_, err := a.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = ?", key)
+	return err
+}
+
+func (s *Storage) EnqueueMail(ctx context.Context, msg model.OutboxMessage) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO outbox(recipient, subject, body, created_at) VALUES (?, ?, ?, ?)",
+		msg.Recipient, msg.Subject, msg.Body, time.Now().Unix(),
+	)
+	return err
+}
+
+// PendingMail returns unsent messages, oldest first, skipping ones that
+// failed too many times.
+func (s *Storage) PendingMail(ctx context.Context, limit int, maxAttempts int) ([]model.OutboxMessage, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, recipient, subject, body, attempts, created_at FROM outbox
+		 WHERE sent_at IS NULL AND attempts < ? ORDER BY id LIMIT ?`,
+		maxAttempts, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []model.OutboxMessage
+	for rows.Next() {
+		var msg model.OutboxMessage
+		var createdAt int64
+		if err := rows.Scan(&msg.ID, &msg.Recipient, &msg.Subject, &msg.Body, &msg.Attempts, &createdAt); err != nil {
+			return nil, err
+		}
+		msg.CreatedAt = time.Unix(createdAt, 0)
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+func (s *Storage) MarkMailSent(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE outbox SET sent_at = ? WHERE id = ?", time.Now().Unix(), id)
+	return err
+}
+
+func (s *Storage) MarkMailFailed(ctx context.Context, id int64, sendErr error) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE id = ?",
+		sendErr.Error(), id,
+	)
+	return err
+}
+
+func (s *Storage) SavePasswordReset(ctx context.Context, reset model.PasswordReset) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO password_resets(user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)",
+		reset.UserID, reset.TokenHash, reset.ExpiresAt.Unix(), time.Now().Unix(),
+	)
+	return err
+}
+
+func (s *Storage) PasswordReset(ctx context.Context, tokenHash string) (model.PasswordReset, error) {
+	var reset model.PasswordReset
+	var expiresAt int64
+	var usedAt sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, user_id, token_hash, expires_at, used_at FROM password_resets WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&reset.ID, &reset.UserID, &reset.TokenHash, &expiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.PasswordReset{}, storage.ErrTokenNotFound
+	}
+	if err != nil {
+		return model.PasswordReset{}, err
+	}
+	reset.ExpiresAt = time.Unix(expiresAt, 0)
+	reset.Used = usedAt.Valid
+
+	return reset, nil
+}
+
+// UsePasswordReset consumes a reset token. It returns storage.ErrTokenUsed
+// if the token was already consumed.
+func (s *Storage) UsePasswordReset(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE password_resets SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().Unix(), id,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrTokenUsed
+	}
+	return nil
+}
+
+// SetTOTPSecret stores a pending secret; it only protects logins once
+// EnableTOTP confirms the user can produce codes for it.
+func (s *Storage) SetTOTPSecret(ctx context.Context, id int64, secret string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users SET totp_secret = ?, totp_enabled = 0, totp_last_step = 0 WHERE id = ?",
+		secret, id,
+	)
+	return err
+}
+
+// EnableTOTP turns 2FA on and replaces the user's recovery codes.
+func (s *Storage) EnableTOTP(ctx context.Context, id int64, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_enabled = 1 WHERE id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes(user_id, code_hash) VALUES (?, ?)", id, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records the last accepted time step. It returns
+// storage.ErrTokenUsed if the step is not newer than the last one, which
+// means the code is being replayed.
+func (s *Storage) UseTOTPStep(ctx context.Context, id int64, step int64) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		step, id, step,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrTokenUsed
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes one unused recovery code of the user. It
+// returns storage.ErrTokenNotFound if no such code is left.
+func (s *Storage) UseRecoveryCode(ctx context.Context, id int64, codeHash string) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now().Unix(), id, codeHash,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrTokenNotFound
+	}
+	return nil
+}
+
+func (s *Storage) SaveOIDCState(ctx context.Context, state model.OIDCState) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO oidc_states(state, provider, nonce, code_verifier, expires_at) VALUES (?, ?, ?, ?, ?)",
+		state.State, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt.Unix(),
+	)
+	return err
+}
+
+// ConsumeOIDCState loads and deletes a pending login, so each state can
+// complete at most one callback. Expired states count as missing.
+func (s *Storage) ConsumeOIDCState(ctx context.Context, state string) (model.OIDCState, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.OIDCState{}, err
+	}
+	defer tx.Rollback()
+
+	out := model.OIDCState{State: state}
+	var expiresAt int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT provider, nonce, code_verifier, expires_at FROM oidc_states WHERE state = ?",
+		state,
+	).Scan(&out.Provider, &out.Nonce, &out.CodeVerifier, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.OIDCState{}, storage.ErrTokenNotFound
+	}
+	if err != nil {
+		return model.OIDCState{}, err
+	}
+
+	now := time.Now().Unix()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM oidc_states WHERE state = ? OR expires_at < ?", state, now); err != nil {
+		return model.OIDCState{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.OIDCState{}, err
+	}
+
+	out.ExpiresAt = time.Unix(expiresAt, 0)
+	if expiresAt < now {
+		return model.OIDCState{}, storage.ErrTokenNotFound
+	}
+	return out, nil
+}
+
+// UserIdentity returns the link for a provider account, or
+// ErrUserNotFound if it has never signed in.
+func (s *Storage) UserIdentity(ctx context.Context, provider, subject string) (model.Identity, error) {
+	id := model.Identity{Provider: provider, Subject: subject}
+	var createdAt int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT user_id, email, created_at FROM user_identities WHERE provider = ? AND subject = ?",
+		provider, subject,
+	).Scan(&id.UserID, &id.Email, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Identity{}, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return model.Identity{}, err
+	}
+	id.CreatedAt = time.Unix(createdAt, 0)
+	return id, nil
+}
+
+func (s *Storage) SaveUserIdentity(ctx context.Context, id model.Identity) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO user_identities(provider, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, ?)",
+		id.Provider, id.Subject, id.UserID, id.Email, id.CreatedAt.Unix(),
+	)
+	return err
+}
+
+// EnsureServiceAccount returns the service account with the given name,
+// creating it first if needed.
+func (s *Storage) EnsureServiceAccount(ctx context.Context, name string) (model.ServiceAccount, error) {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR IGNORE INTO service_accounts(name, created_at) VALUES (?, ?)",
+		name, time.Now().Unix(),
+	)
+	if err != nil {
+		return model.ServiceAccount{}, err
+	}
+
+	account := model.ServiceAccount{Name: name}
+	var createdAt int64
+	err = s.db.QueryRowContext(ctx,
+		"SELECT id, created_at FROM service_accounts WHERE name = ?", name,
+	).Scan(&account.ID, &createdAt)
+	if err != nil {
+		return model.ServiceAccount{}, err
+	}
+	account.CreatedAt = time.Unix(createdAt, 0)
+	return account, nil
+}
+
+func (s *Storage) SaveAPIKey(ctx context.Context, key model.APIKey) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO api_keys(service_account_id, prefix, secret_hash, scopes, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		key.ServiceAccountID, key.Prefix, key.SecretHash, strings.Join(key.Scopes, ","), key.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+const apiKeyColumns = `k.id, k.service_account_id, a.name, k.prefix, k.secret_hash, k.scopes,
+	k.created_at, k.last_used_at, k.revoked`
+
+func scanAPIKey(row scanner) (model.APIKey, error) {
+	var (
+		key                   model.APIKey
+		scopes                string
+		createdAt, lastUsedAt int64
+	)
+	err := row.Scan(&key.ID, &key.ServiceAccountID, &key.ServiceAccount, &key.Prefix, &key.SecretHash,
+		&scopes, &createdAt, &lastUsedAt, &key.Revoked)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.CreatedAt = time.Unix(createdAt, 0)
+	if lastUsedAt > 0 {
+		key.LastUsedAt = time.Unix(lastUsedAt, 0)
+	}
+	return key, nil
+}
+
+// APIKeys lists keys, newest first, optionally only those of one service
+// account.
+func (s *Storage) APIKeys(ctx context.Context, serviceAccount string) ([]model.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+` FROM api_keys k JOIN service_accounts a ON a.id = k.service_account_id
+		 WHERE ? = '' OR a.name = ? ORDER BY k.id DESC`,
+		serviceAccount, serviceAccount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *Storage) APIKeyByPrefix(ctx context.Context, prefix string) (model.APIKey, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+` FROM api_keys k JOIN service_accounts a ON a.id = k.service_account_id
+		 WHERE k.prefix = ?`,
+		prefix,
+	)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.APIKey{}, storage.ErrTokenNotFound
+	}
+	return key, err
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE api_keys SET revoked = 1 WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrTokenNotFound
+	}
+	return err
+}
+
+func (s *Storage) TouchAPIKey(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", time.Now().Unix(), id)
+	return err
+}
+
+func (s *Storage) SaveAuditEvent(ctx context.Context, e model.AuditEvent) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO audit_events(type, user_id, actor_id, email, ip, user_agent, success, detail, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Type, e.UserID, e.ActorID, e.Email, e.IP, e.UserAgent, e.Success, e.Detail, e.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// AuditEvents returns one page of events matching f, newest first, and the
+// total number of matches.
+func (s *Storage) AuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, int, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if f.UserID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, f.Type)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since.Unix())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.Until.Unix())
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_events"+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, type, user_id, actor_id, email, ip, user_agent, success, detail, created_at
+		 FROM audit_events`+cond+" ORDER BY id DESC LIMIT ? OFFSET ?",
+		append(args, f.Limit, f.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []model.AuditEvent
+	for rows.Next() {
+		var (
+			e         model.AuditEvent
+			createdAt int64
+		)
+		err := rows.Scan(&e.ID, &e.Type, &e.UserID, &e.ActorID, &e.Email, &e.IP, &e.UserAgent, &e.Success, &e.Detail, &createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.CreatedAt = time.Unix(createdAt, 0)
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
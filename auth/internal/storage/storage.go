@@ -1 +1,10 @@
 package storage
+
+import "errors"
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUserExists    = errors.New("user already exists")
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenUsed     = errors.New("token already used")
+)
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN roles;
\ No newline at end of file
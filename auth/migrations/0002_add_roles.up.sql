@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN roles TEXT NOT NULL DEFAULT 'customer';
\ No newline at end of file
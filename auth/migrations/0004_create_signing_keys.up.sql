@@ -0,0 +1,6 @@
+CREATE TABLE signing_keys (
+  kid TEXT PRIMARY KEY,
+  private_key BLOB NOT NULL,
+  created_at INTEGER NOT NULL,
+  expires_at INTEGER NOT NULL
+);
@@ -0,0 +1,4 @@
+CREATE TABLE revoked_tokens (
+  jti TEXT PRIMARY KEY,
+  expires_at INTEGER NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS outbox;
+ALTER TABLE users DROP COLUMN email_verified;
@@ -0,0 +1,5 @@
+ALTER TABLE refresh_tokens DROP COLUMN mfa;
+DROP TABLE IF EXISTS recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled;
+ALTER TABLE users DROP COLUMN totp_secret;
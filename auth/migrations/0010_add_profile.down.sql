@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_at;
+ALTER TABLE users DROP COLUMN preferred_currency;
+ALTER TABLE users DROP COLUMN phone;
+ALTER TABLE users DROP COLUMN display_name;
@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN phone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN preferred_currency TEXT NOT NULL DEFAULT 'USD';
+ALTER TABLE users ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users SET created_at = strftime('%s', 'now'), updated_at = strftime('%s', 'now');
@@ -0,0 +1,25 @@
+CREATE TABLE audit_events (
+  id INTEGER PRIMARY KEY,
+  type TEXT NOT NULL,
+  user_id INTEGER NOT NULL DEFAULT 0,
+  actor_id INTEGER NOT NULL DEFAULT 0,
+  email TEXT NOT NULL DEFAULT '',
+  ip TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  success INTEGER NOT NULL DEFAULT 1,
+  detail TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_audit_events_user ON audit_events (user_id, created_at);
+CREATE INDEX idx_audit_events_type ON audit_events (type, created_at);
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+  SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+  SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
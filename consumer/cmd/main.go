@@ -1,20 +1,89 @@
 package main
 
 import (
+	"consumer/internal/configs"
 	server "consumer/internal/nats_server"
 	"consumer/internal/store/sqlite"
+	"consumer/migrations"
+	"context"
+	"database/sql"
+	"flag"
+	"github.com/barcek2281/comics-store/pkg/migrator"
+	"github.com/barcek2281/comics-store/pkg/requestid"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
+var (
+	configPath  string
+	printConfig bool
+)
+
+func init() {
+	flag.StringVar(&configPath, "config-path", "./configs/local.yaml", "config path")
+	flag.BoolVar(&printConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+}
+
 func main() {
-	store := sqlite.NewStore("./storage/log.db")
-	s := server.NewNatsServer(store, 50052)
+	flag.Parse()
 
-	_, err := s.NC.Subscribe("order.created", s.HandleCreateOrder)
+	cfg := configs.MustLoad(configPath)
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("cannot print config: %v", err)
+		}
+		return
+	}
+
+	level, _ := cfg.Level()
+	slog.SetDefault(slog.New(requestid.NewHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))))
+
+	db, err := sql.Open("sqlite3", cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("error to open database: %v", err)
+	}
+	m := migrator.New(db, migrations.FS)
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "migrate" {
+			log.Fatalf("unknown command %q", args[0])
+		}
+		err := m.Run(context.Background(), os.Stdout, args[1:])
+		db.Close()
+		if err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		log.Fatalf("error to apply migrations: %v", err)
+	}
+	db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store := sqlite.NewStore(cfg.StoragePath)
+	s := server.NewNatsServer(store, cfg.NatsURL, cfg.Upstreams.Inventory, cfg.ServiceAPIKey, cfg.RequestTimeout)
+
+	_, err = s.NC.Subscribe("order.created", s.HandleCreateOrder)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Subscribed to order.created events")
-	select {} // keep running
+	<-ctx.Done()
+
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		slog.Error("cannot drain nats subscription", "error", err)
+	}
+	if err := store.Close(); err != nil {
+		slog.Error("cannot close storage", "error", err)
+	}
+	slog.Info("stopped")
 }
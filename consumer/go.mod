@@ -5,12 +5,15 @@ go 1.23.0
 toolchain go1.23.8
 
 require (
+	github.com/barcek2281/comics-store/pkg v0.0.0-00010101000000-000000000000
+	github.com/ilyakaznacheev/cleanenv v1.5.0
 	github.com/mattn/go-sqlite3 v1.14.28
 	github.com/nats-io/nats.go v1.41.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/barcek2281/proto v0.0.0-20250412082746-8e5f3c47245f // indirect
+	github.com/barcek2281/proto v0.0.0-00010101000000-000000000000
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/nats-io/nkeys v0.4.11 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
@@ -19,6 +22,16 @@ require (
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250218202821-56aae31c358a // indirect
-	google.golang.org/grpc v1.72.0 // indirect
+	google.golang.org/grpc v1.72.0
 	google.golang.org/protobuf v1.36.6 // indirect
 )
+
+require (
+	github.com/BurntSushi/toml v1.5.0 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	olympos.io/encoding/edn v0.0.0-20201019073823-d3554ca0b0a3 // indirect
+)
+
+replace github.com/barcek2281/proto => ../proto
+
+replace github.com/barcek2281/comics-store/pkg => ../pkg
@@ -0,0 +1,95 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Config is read from a YAML file; every field can be overridden by the
+// environment variable named in its env tag.
+type Config struct {
+	LogLevel        string        `yaml:"log_level" env:"LOG_LEVEL" env-default:"info"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
+	StoragePath     string        `yaml:"storage_path" env:"STORAGE_PATH" env-default:"./storage/log.db"`
+	NatsURL         string        `yaml:"nats_url" env:"NATS_URL" env-default:"nats://nats:4222"`
+	RequestTimeout  time.Duration `yaml:"request_timeout" env:"REQUEST_TIMEOUT" env-default:"10s"`
+	Upstreams       Upstreams     `yaml:"upstreams"`
+	// ServiceAPIKey authenticates stock updates against inventory. It
+	// needs the inventory:write scope.
+	ServiceAPIKey string `yaml:"service_api_key" env:"SERVICE_API_KEY"`
+}
+
+// Upstreams are the gRPC addresses of the services this one calls.
+type Upstreams struct {
+	Inventory string `yaml:"inventory" env:"INVENTORY_ADDR" env-default:"inventory:50052"`
+}
+
+func MustLoad(configPath string) *Config {
+	var config Config
+
+	if err := cleanenv.ReadConfig(configPath, &config); err != nil {
+		panic(err)
+	}
+	if err := config.Validate(); err != nil {
+		panic(fmt.Errorf("invalid config %s: %w", configPath, err))
+	}
+
+	return &config
+}
+
+// Validate reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := c.Level(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.StoragePath == "" {
+		errs = append(errs, errors.New("storage_path is required"))
+	}
+	if c.NatsURL == "" {
+		errs = append(errs, errors.New("nats_url is required"))
+	}
+	if c.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("request_timeout must be positive"))
+	}
+	if c.Upstreams.Inventory == "" {
+		errs = append(errs, errors.New("upstreams.inventory is required"))
+	}
+	if c.ServiceAPIKey == "" {
+		errs = append(errs, errors.New("service_api_key is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// Level parses LogLevel.
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("log_level: %w", err)
+	}
+	return level, nil
+}
+
+// Print writes the config as YAML with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	if c.ServiceAPIKey != "" {
+		c.ServiceAPIKey = redacted
+	}
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -5,53 +5,88 @@ import (
 	"consumer/internal/store/sqlite"
 	"context"
 	"encoding/json"
-	"fmt"
+	"github.com/barcek2281/comics-store/pkg/requestid"
 	"log"
 	"log/slog"
 	"strconv"
+	"time"
 
 	inventoryv1 "github.com/barcek2281/proto/gen/go/inventory"
 	"github.com/nats-io/nats.go"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 type NatsServer struct {
 	NC              *nats.Conn
+	closed          chan struct{}
+	conn            *grpc.ClientConn
 	inventoryClient inventoryv1.InventoryClient
 	store           *sqlite.Store
+	apiKey          string
+	timeout         time.Duration
 }
 
-func NewNatsServer(store *sqlite.Store, inventoryPort int) *NatsServer {
-	nc, err := nats.Connect("nats://nats:4222")
+func NewNatsServer(store *sqlite.Store, natsURL, inventoryAddr, apiKey string, timeout time.Duration) *NatsServer {
+	closed := make(chan struct{})
+	nc, err := nats.Connect(natsURL, nats.ClosedHandler(func(*nats.Conn) { close(closed) }))
 	if err != nil {
 		log.Fatalf("error: %v", err)
 	}
 
-	conn, err := grpc.NewClient(fmt.Sprintf("inventory:%d", inventoryPort), grpc.WithInsecure())
+	conn, err := grpc.NewClient(inventoryAddr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor))
 	if err != nil {
-		log.Fatalf("error to connect grpc, error: %v, port: %d", err, inventoryPort)
+		log.Fatalf("error to connect grpc, error: %v, addr: %s", err, inventoryAddr)
 	}
 	client := inventoryv1.NewInventoryClient(conn)
 	return &NatsServer{
 		NC:              nc,
+		closed:          closed,
+		conn:            conn,
 		store:           store,
 		inventoryClient: client,
+		apiKey:          apiKey,
+		timeout:         timeout,
 	}
 }
 
+// Shutdown drains the connection: subscriptions stop receiving, messages
+// already delivered are still handled, then the connection closes. It
+// returns once that is done or ctx expires.
+func (n *NatsServer) Shutdown(ctx context.Context) error {
+	if err := n.NC.Drain(); err != nil {
+		return err
+	}
+
+	select {
+	case <-n.closed:
+	case <-ctx.Done():
+		n.NC.Close()
+		return ctx.Err()
+	}
+	return n.conn.Close()
+}
+
 func (n *NatsServer) HandleCreateOrder(m *nats.Msg) {
+	// The order service passes on the id of the request that created the
+	// order, so this handler logs and calls inventory under the same id.
+	ctx := requestid.WithID(context.Background(), requestid.Sanitize(m.Header.Get(requestid.Header)))
 	var order models.Order
 
 	if err := json.Unmarshal(m.Data, &order); err != nil {
-		slog.Error("cannot parse data", "error", err)
+		slog.ErrorContext(ctx, "cannot parse data", "error", err)
 		return
 	}
 
 	err := n.store.WriteCreatedOrder(order)
 	if err != nil {
-		slog.Error("error to write db", "error", err)
+		slog.ErrorContext(ctx, "error to write db", "error", err)
 	}
-	ctx := context.Background()
+	// Stock updates are a privileged inventory call; the consumer
+	// authenticates with an API key scoped to inventory:write.
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", n.apiKey)
 	for _, item := range order.Items {
 
 		newId, _ := strconv.Atoi(item.ProductId)
@@ -60,7 +95,7 @@ func (n *NatsServer) HandleCreateOrder(m *nats.Msg) {
 		})
 
 		if err != nil {
-			slog.Warn("error to get value")
+			slog.WarnContext(ctx, "error to get value")
 			continue
 		}
 
@@ -73,5 +108,5 @@ func (n *NatsServer) HandleCreateOrder(m *nats.Msg) {
 			Price:       int64(comics.Price),
 		})
 	}
-	slog.Info("recieve data", "data", order)
+	slog.InfoContext(ctx, "recieve data", "data", order)
 }
@@ -25,6 +25,10 @@ func NewStore(storagePath string) *Store {
 	}
 }
 
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
 func (s *Store) WriteCreatedOrder(order models.Order) error {
 
 	_, err := s.db.Exec("INSERT INTO order_log(id, price, status, user_id, create_at) VALUES (?, ?, ?, ?, ?)", order.Id, order.TotalPrice, order.Status, order.UserId, order.CreatedAt)
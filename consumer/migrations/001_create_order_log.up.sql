@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS order_log (
+  id TEXT PRIMARY KEY,
+  price REAL NOT NULL,
+  status TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  create_at TEXT NOT NULL
+);
@@ -1,33 +1,154 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/barcek2281/comics-store/inventory/internal/configs"
 	grpcserver "github.com/barcek2281/comics-store/inventory/internal/grpcServer"
 	"github.com/barcek2281/comics-store/inventory/internal/storage/sqlite"
+	"github.com/barcek2281/comics-store/inventory/migrations"
+	"github.com/barcek2281/comics-store/pkg/interceptor"
+	"github.com/barcek2281/comics-store/pkg/migrator"
+	"github.com/barcek2281/comics-store/pkg/requestid"
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
 	inventoryv1 "github.com/barcek2281/proto/gen/go/inventory"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+var (
+	configPath  string
+	printConfig bool
+)
+
+func init() {
+	flag.StringVar(&configPath, "config-path", "./configs/local.yaml", "config path")
+	flag.BoolVar(&printConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+}
+
 func main() {
-	lis, err := net.Listen("tcp", ":50052")
+	flag.Parse()
+
+	cfg := configs.MustLoad(configPath)
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("cannot print config: %v", err)
+		}
+		return
+	}
+
+	level, _ := cfg.Level()
+	slog.SetDefault(slog.New(requestid.NewHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))))
+
+	db, err := sql.Open("sqlite3", cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("error to open database: %v", err)
+	}
+	m := migrator.New(db, migrations.FS)
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "migrate" {
+			log.Fatalf("unknown command %q", args[0])
+		}
+		err := m.Run(context.Background(), os.Stdout, args[1:])
+		db.Close()
+		if err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		log.Fatalf("error to apply migrations: %v", err)
+	}
+	db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
 	if err != nil {
-		log.Fatalf("failed to listen on port 50052: %v", err)
+		log.Fatalf("failed to listen on port %d: %v", cfg.Port, err)
 	}
 
-	store, err := sqlite.NewStorage("./storage/database.db")
+	store, err := sqlite.NewStorage(cfg.StoragePath)
 	if err != nil {
 		log.Fatalf("error to load storage: %v", err)
 	}
 
 	g := grpcserver.New(store)
 
-	s := grpc.NewServer()
+	authConn, err := grpc.NewClient(cfg.Upstreams.Auth,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor),
+	)
+	if err != nil {
+		log.Fatalf("failed to connect to auth service: %v", err)
+	}
+	auth := interceptor.NewAuth(
+		authv1.NewAuthClient(authConn),
+		[]string{inventoryv1.Inventory_Get_FullMethodName, inventoryv1.Inventory_List_FullMethodName},
+		map[string][]string{
+			inventoryv1.Inventory_Create_FullMethodName: {"staff", "admin", "service"},
+			inventoryv1.Inventory_Update_FullMethodName: {"staff", "admin", "service"},
+			inventoryv1.Inventory_Delete_FullMethodName: {"staff", "admin", "service"},
+		},
+		map[string]string{
+			inventoryv1.Inventory_Create_FullMethodName: "inventory:write",
+			inventoryv1.Inventory_Update_FullMethodName: "inventory:write",
+			inventoryv1.Inventory_Delete_FullMethodName: "inventory:write",
+		},
+		[]string{
+			inventoryv1.Inventory_Create_FullMethodName,
+			inventoryv1.Inventory_Update_FullMethodName,
+			inventoryv1.Inventory_Delete_FullMethodName,
+		},
+	)
+
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor, auth.Unary()))
 	inventoryv1.RegisterInventoryServer(s, g)
 
-	log.Printf("gRPC server listening at %v", lis.Addr())
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	go func() {
+		log.Printf("gRPC server listening at %v", lis.Addr())
+		if err := s.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	gracefulStop(s, cfg.ShutdownTimeout)
+
+	if err := authConn.Close(); err != nil {
+		slog.Warn("cannot close auth connection", "error", err)
+	}
+	if err := store.Close(); err != nil {
+		slog.Error("cannot close storage", "error", err)
+	}
+	slog.Info("stopped")
+}
+
+// gracefulStop lets in-flight RPCs finish and forces the remaining ones
+// closed once timeout has passed.
+func gracefulStop(s *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("graceful stop timed out, closing remaining connections")
+		s.Stop()
 	}
 }
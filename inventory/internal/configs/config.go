@@ -0,0 +1,80 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is read from a YAML file; every field can be overridden by the
+// environment variable named in its env tag.
+type Config struct {
+	Port            int           `yaml:"port" env:"PORT" env-default:"50052"`
+	LogLevel        string        `yaml:"log_level" env:"LOG_LEVEL" env-default:"info"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
+	StoragePath     string        `yaml:"storage_path" env:"STORAGE_PATH" env-default:"./storage/database.db"`
+	Upstreams       Upstreams     `yaml:"upstreams"`
+}
+
+// Upstreams are the gRPC addresses of the services this one calls.
+type Upstreams struct {
+	Auth string `yaml:"auth" env:"AUTH_ADDR" env-default:"auth:50051"`
+}
+
+func MustLoad(configPath string) *Config {
+	var config Config
+
+	if err := cleanenv.ReadConfig(configPath, &config); err != nil {
+		panic(err)
+	}
+	if err := config.Validate(); err != nil {
+		panic(fmt.Errorf("invalid config %s: %w", configPath, err))
+	}
+
+	return &config
+}
+
+// Validate reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	if _, err := c.Level(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.StoragePath == "" {
+		errs = append(errs, errors.New("storage_path is required"))
+	}
+	if c.Upstreams.Auth == "" {
+		errs = append(errs, errors.New("upstreams.auth is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// Level parses LogLevel.
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("log_level: %w", err)
+	}
+	return level, nil
+}
+
+// Print writes the config as YAML. This service has no secrets to redact.
+func (c Config) Print(w io.Writer) error {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -2,11 +2,16 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/barcek2281/comics-store/inventory/internal/model"
+	"github.com/barcek2281/comics-store/inventory/internal/storage"
 	"github.com/barcek2281/comics-store/inventory/internal/storage/sqlite"
+	"github.com/barcek2281/comics-store/pkg/validate"
 	inventoryv1 "github.com/barcek2281/proto/gen/go/inventory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type GRPCserver struct {
@@ -30,9 +35,13 @@ func (g *GRPCserver) Create(ctx context.Context, in *inventoryv1.CreateRequest)
 		Quantity:    int32(in.GetQuantity()),
 	}
 
+	if err := validate.Struct(comic); err != nil {
+		return nil, err
+	}
+
 	id, err := g.store.Create(comic)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create comic: %w", err)
+		return nil, status.Error(codes.Internal, "create comic")
 	}
 
 	return &inventoryv1.CreateResponce{
@@ -43,19 +52,19 @@ func (g *GRPCserver) Create(ctx context.Context, in *inventoryv1.CreateRequest)
 func (g *GRPCserver) Delete(ctx context.Context, in *inventoryv1.DeleteRequest) (*inventoryv1.DeleteResponce, error) {
 	err := g.store.Delete(in.GetId())
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete comic: %w", err)
+		return nil, storeError(err, "delete comic")
 	}
 
 	return &inventoryv1.DeleteResponce{
 		IsDeleted: true,
-		Result: "",
+		Result:    "",
 	}, nil
 }
 
 func (g *GRPCserver) Get(ctx context.Context, in *inventoryv1.GetRequest) (*inventoryv1.Comics, error) {
 	comic, err := g.store.Get(in.GetId())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get comic: %w", err)
+		return nil, storeError(err, "get comic")
 	}
 
 	return &inventoryv1.Comics{
@@ -72,7 +81,7 @@ func (g *GRPCserver) Get(ctx context.Context, in *inventoryv1.GetRequest) (*inve
 func (g *GRPCserver) List(ctx context.Context, in *inventoryv1.ListRequest) (*inventoryv1.ListResponse, error) {
 	comics, err := g.store.List()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list comics: %w", err)
+		return nil, status.Error(codes.Internal, "list comics")
 	}
 
 	var list []*inventoryv1.Comics
@@ -102,13 +111,26 @@ func (g *GRPCserver) Update(ctx context.Context, in *inventoryv1.UpdateRequest)
 		Quantity:    int32(in.GetQuantity()),
 	}
 
+	if err := validate.Struct(comic); err != nil {
+		return nil, err
+	}
+
 	err := g.store.Update(comic)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update comic: %w", err)
+		return nil, storeError(err, "update comic")
 	}
 
 	return &inventoryv1.UpdateResponce{
 		Successfully: true,
-		Result: "",
+		Result:       "",
 	}, nil
 }
+
+// storeError turns a storage error into a status, keeping the details of
+// unexpected failures out of the response.
+func storeError(err error, op string) error {
+	if errors.Is(err, storage.ErrComicNotFound) {
+		return status.Error(codes.NotFound, "comic not found")
+	}
+	return status.Error(codes.Internal, op)
+}
@@ -1,11 +1,13 @@
 package model
 
+// Comics is validated with the same rules as the gateway's inventory
+// requests; see the validate package.
 type Comics struct {
 	ID          int64   `json:"id"`
-	Title       string  `json:"title"`       // Comic title
-	Author      string  `json:"author"`      // Author's name
-	Description string  `json:"description"` // Description of the comic
-	ReleaseDate string  `json:"releaseDate"` // Release date (can be formatted time.Time if needed)
-	Price       float32 `json:"price"`       // Price of the comic
-	Quantity    int32   `json:"quantity"`    // Available quantity
+	Title       string  `json:"title" validate:"required,max=200"`  // Comic title
+	Author      string  `json:"author" validate:"required,max=100"` // Author's name
+	Description string  `json:"description" validate:"max=2000"`    // Description of the comic
+	ReleaseDate string  `json:"releaseDate" validate:"date"`        // Release date, YYYY-MM-DD
+	Price       float32 `json:"price" validate:"min=0"`             // Price of the comic
+	Quantity    int32   `json:"quantity" validate:"min=0"`          // Available quantity
 }
@@ -3,10 +3,12 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/barcek2281/comics-store/inventory/internal/model"
+	"github.com/barcek2281/comics-store/inventory/internal/storage"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -25,6 +27,10 @@ func NewStorage(storagePath string) (*Storage, error) {
 	return &Storage{db: db}, nil
 }
 
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
 func (s *Storage) Create(comics model.Comics) (int64, error) {
 	stmt, err := s.db.Prepare(`
 		INSERT INTO comics(title, author, description, release_date, price, quantity)
@@ -51,8 +57,11 @@ func (s *Storage) Create(comics model.Comics) (int64, error) {
 }
 // Delete deletes a comic by ID
 func (s *Storage) Delete(id int64) error {
-	_, err := s.db.Exec("DELETE FROM comics WHERE id = ?", id)
-	return err
+	res, err := s.db.Exec("DELETE FROM comics WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return mustAffect(res)
 }
 
 // Get fetches a comic by ID
@@ -69,6 +78,9 @@ func (s *Storage) Get(id int64) (model.Comics, error) {
 		&comic.Price,
 		&comic.Quantity,
 	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Comics{}, storage.ErrComicNotFound
+	}
 	if err != nil {
 		return model.Comics{}, err
 	}
@@ -111,7 +123,7 @@ func (s *Storage) Update(comic model.Comics) error {
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec(`
+	res, err := tx.Exec(`
 		UPDATE comics
 		SET title = ?, author = ?, description = ?, release_date = ?, price = ?, quantity = ?
 		WHERE id = ?
@@ -126,6 +138,23 @@ func (s *Storage) Update(comic model.Comics) error {
 	)
 	if err != nil {
 		tx.Rollback()
+		return err
+	}
+	if err := mustAffect(res); err != nil {
+		tx.Rollback()
+		return err
 	}
 	return tx.Commit()
 }
+
+// mustAffect reports ErrComicNotFound when a statement matched no rows.
+func mustAffect(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrComicNotFound
+	}
+	return nil
+}
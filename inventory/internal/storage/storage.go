@@ -0,0 +1,5 @@
+package storage
+
+import "errors"
+
+var ErrComicNotFound = errors.New("comic not found")
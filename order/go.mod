@@ -3,9 +3,12 @@ module github.com/barcek2281/comics-store/order
 go 1.22.2
 
 require (
-	github.com/barcek2281/proto v0.0.0-20250412082746-8e5f3c47245f
+	github.com/barcek2281/comics-store/pkg v0.0.0-00010101000000-000000000000
+	github.com/barcek2281/proto v0.0.0-00010101000000-000000000000
 	github.com/google/uuid v1.6.0
+	github.com/ilyakaznacheev/cleanenv v1.5.0
 	google.golang.org/grpc v1.71.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -16,3 +19,13 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 )
+
+require (
+	github.com/BurntSushi/toml v1.5.0 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	olympos.io/encoding/edn v0.0.0-20201019073823-d3554ca0b0a3 // indirect
+)
+
+replace github.com/barcek2281/proto => ../proto
+
+replace github.com/barcek2281/comics-store/pkg => ../pkg
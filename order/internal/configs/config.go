@@ -0,0 +1,95 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is read from a YAML file; every field can be overridden by the
+// environment variable named in its env tag.
+type Config struct {
+	Port            int           `yaml:"port" env:"PORT" env-default:"50053"`
+	LogLevel        string        `yaml:"log_level" env:"LOG_LEVEL" env-default:"info"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
+	StoragePath     string        `yaml:"storage_path" env:"STORAGE_PATH" env-default:"./storage/database.db"`
+	Upstreams       Upstreams     `yaml:"upstreams"`
+	Producer        Producer      `yaml:"producer"`
+}
+
+// Upstreams are the gRPC addresses of the services this one calls.
+type Upstreams struct {
+	Auth      string `yaml:"auth" env:"AUTH_ADDR" env-default:"auth:50051"`
+	Inventory string `yaml:"inventory" env:"INVENTORY_ADDR" env-default:"inventory:50052"`
+}
+
+// Producer is the HTTP service that publishes created orders on NATS.
+type Producer struct {
+	URL     string        `yaml:"url" env:"PRODUCER_URL" env-default:"http://producer:8181"`
+	Timeout time.Duration `yaml:"timeout" env:"PRODUCER_TIMEOUT" env-default:"5s"`
+}
+
+func MustLoad(configPath string) *Config {
+	var config Config
+
+	if err := cleanenv.ReadConfig(configPath, &config); err != nil {
+		panic(err)
+	}
+	if err := config.Validate(); err != nil {
+		panic(fmt.Errorf("invalid config %s: %w", configPath, err))
+	}
+
+	return &config
+}
+
+// Validate reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	if _, err := c.Level(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if c.StoragePath == "" {
+		errs = append(errs, errors.New("storage_path is required"))
+	}
+	if c.Upstreams.Auth == "" || c.Upstreams.Inventory == "" {
+		errs = append(errs, errors.New("upstreams.auth and upstreams.inventory are required"))
+	}
+	if u, err := url.Parse(c.Producer.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("producer.url %q is not an absolute URL", c.Producer.URL))
+	}
+	if c.Producer.Timeout <= 0 {
+		errs = append(errs, errors.New("producer.timeout must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Level parses LogLevel.
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("log_level: %w", err)
+	}
+	return level, nil
+}
+
+// Print writes the config as YAML. This service has no secrets to redact.
+func (c Config) Print(w io.Writer) error {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -4,28 +4,67 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/barcek2281/comics-store/order/internal/storage"
+	"github.com/barcek2281/comics-store/pkg/interceptor"
+	"github.com/barcek2281/comics-store/pkg/requestid"
+	"github.com/barcek2281/comics-store/pkg/validate"
 	orderv1 "github.com/barcek2281/proto/gen/go/order"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// createOrderInput mirrors CreateOrderRequest with the rules the gateway
+// applies to order payloads.
+type createOrderInput struct {
+	UserID          string           `json:"user_id" validate:"required"`
+	ClientReference string           `json:"client_reference" validate:"max=64"`
+	Items           []orderItemInput `json:"items" validate:"required,max=100"`
+}
+
+type orderItemInput struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int32  `json:"quantity" validate:"min=1,max=1000"`
+}
+
 type GRPCserver struct {
 	orderv1.UnimplementedOrderServer
-	store *storage.Storage
+	store       *storage.Storage
+	producerURL string
+	producer    *http.Client
 }
 
-func NewGRPCserver(store *storage.Storage) *GRPCserver {
+// NewGRPCserver returns the order service. Created orders are posted to the
+// producer at producerURL, giving up after producerTimeout.
+func NewGRPCserver(store *storage.Storage, producerURL string, producerTimeout time.Duration) *GRPCserver {
 	return &GRPCserver{
-		store: store,
+		store:       store,
+		producerURL: strings.TrimSuffix(producerURL, "/"),
+		producer:    &http.Client{Timeout: producerTimeout},
 	}
 }
 
 func (g *GRPCserver) CreateOrder(ctx context.Context, in *orderv1.CreateOrderRequest) (*orderv1.CreateOrderResponse, error) {
+	userID, err := ordersOf(ctx, in.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	input := createOrderInput{UserID: userID, ClientReference: in.ClientReference}
+	for _, item := range in.Items {
+		input.Items = append(input.Items, orderItemInput{ProductID: item.ProductId, Quantity: item.Quantity})
+	}
+	if err := validate.Struct(input); err != nil {
+		return nil, err
+	}
+
 	orderID := uuid.New().String()
 	totalPrice := float32(0)
 
@@ -34,38 +73,25 @@ func (g *GRPCserver) CreateOrder(ctx context.Context, in *orderv1.CreateOrderReq
 	}
 
 	order := &orderv1.Order{
-		Id:         orderID,
-		UserId:     in.UserId,
-		Items:      in.Items,
-		TotalPrice: totalPrice,
-		Status:     "created",
-		CreatedAt:  time.Now().Format(time.RFC3339),
+		Id:              orderID,
+		UserId:          userID,
+		Items:           in.Items,
+		TotalPrice:      totalPrice,
+		Status:          "created",
+		CreatedAt:       time.Now().Format(time.RFC3339),
+		ClientReference: in.ClientReference,
 	}
 
-	err := g.store.CreateOrder(ctx, order)
-	if err != nil {
-		fmt.Printf("error to create order: %v", err)
-		return nil, err
+	err = g.store.CreateOrder(ctx, order)
+	if errors.Is(err, storage.ErrDuplicateReference) {
+		existing, _ := g.store.OrderIDByReference(ctx, userID, in.ClientReference)
+		return nil, status.Errorf(codes.AlreadyExists, "client_reference is already used by order %s", existing)
 	}
-	bites, err := json.Marshal(order)
 	if err != nil {
-		fmt.Printf("error to marshal json: %v", err)
-	} else {
-		b := bytes.NewBuffer(bites)
-		req, err := http.NewRequest(http.MethodPost, "http://producer:8181/create-order", b)
-		if err != nil {
-			fmt.Println("erro to req:  ", err)
-		}
-		res, err := http.DefaultClient.Do(req)
-		if err != nil {
-			fmt.Printf("client: error making http request: %s\n", err)
-		}
-
-		if res.StatusCode != 200 {
-			slog.Error("error recieve message", "status", res.StatusCode)
-		}
-		slog.Info("request to create order producer")
+		slog.ErrorContext(ctx, "error to create order", "error", err)
+		return nil, storeError(err, "create order")
 	}
+	g.notifyProducer(ctx, order)
 
 	return &orderv1.CreateOrderResponse{
 		OrderId: orderID,
@@ -73,29 +99,57 @@ func (g *GRPCserver) CreateOrder(ctx context.Context, in *orderv1.CreateOrderReq
 	}, nil
 }
 
-func (g *GRPCserver) GetOrder(ctx context.Context, in *orderv1.GetOrderRequest) (*orderv1.Order, error) {
-	order, err := g.store.GetOrderByID(ctx, in.OrderId)
+// notifyProducer hands a created order to the producer, which publishes
+// it on NATS, along with the request id. The order is stored by then, so
+// failures are only logged.
+func (g *GRPCserver) notifyProducer(ctx context.Context, order *orderv1.Order) {
+	b, err := json.Marshal(order)
 	if err != nil {
-		return nil, err
+		slog.ErrorContext(ctx, "error to marshal json", "error", err)
+		return
 	}
-	return order, nil
+	req, err := http.NewRequest(http.MethodPost, g.producerURL+"/create-order", bytes.NewReader(b))
+	if err != nil {
+		slog.ErrorContext(ctx, "error to build producer request", "error", err)
+		return
+	}
+	req.Header.Set(requestid.Header, requestid.FromContext(ctx))
+
+	res, err := g.producer.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "error making producer request", "error", err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		slog.ErrorContext(ctx, "error recieve message", "status", res.StatusCode)
+		return
+	}
+	slog.InfoContext(ctx, "request to create order producer")
+}
+
+func (g *GRPCserver) GetOrder(ctx context.Context, in *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	return g.ownOrder(ctx, in.OrderId)
 }
 
 func (g *GRPCserver) UpdateOrder(ctx context.Context, in *orderv1.GetOrderRequest) (*orderv1.UpdateOrderResponce, error) {
+	if _, err := g.ownOrder(ctx, in.OrderId); err != nil {
+		return nil, err
+	}
 	err := g.store.UpdateOrderStatus(ctx, in.OrderId, "updated")
 	if err != nil {
-		return &orderv1.UpdateOrderResponce{Status: "failed"}, err
+		return nil, storeError(err, "update order")
 	}
 	return &orderv1.UpdateOrderResponce{Status: "updated"}, nil
 }
 
 func (g *GRPCserver) CloseOrder(ctx context.Context, in *orderv1.CloseOrderRequest) (*orderv1.CloseOrderResponce, error) {
-	err := g.store.CloseOrderByUserID(ctx, in.UserId)
+	userID, err := ordersOf(ctx, in.UserId)
 	if err != nil {
-		return &orderv1.CloseOrderResponce{
-			IsChanged: false,
-			Status:    "failed",
-		}, err
+		return nil, err
+	}
+	if err := g.store.CloseOrderByUserID(ctx, userID); err != nil {
+		return nil, storeError(err, "close orders")
 	}
 	return &orderv1.CloseOrderResponce{
 		IsChanged: true,
@@ -104,12 +158,12 @@ func (g *GRPCserver) CloseOrder(ctx context.Context, in *orderv1.CloseOrderReque
 }
 
 func (g *GRPCserver) DeleteOrder(ctx context.Context, in *orderv1.DeleteOrderRequest) (*orderv1.CloseOrderResponce, error) {
-	err := g.store.DeleteOrderByUserID(ctx, in.UserId)
+	userID, err := ordersOf(ctx, in.UserId)
 	if err != nil {
-		return &orderv1.CloseOrderResponce{
-			IsChanged: false,
-			Status:    "failed",
-		}, err
+		return nil, err
+	}
+	if err := g.store.DeleteOrderByUserID(ctx, userID); err != nil {
+		return nil, storeError(err, "delete orders")
 	}
 	return &orderv1.CloseOrderResponce{
 		IsChanged: true,
@@ -118,9 +172,77 @@ func (g *GRPCserver) DeleteOrder(ctx context.Context, in *orderv1.DeleteOrderReq
 }
 
 func (g *GRPCserver) ListOrders(ctx context.Context, in *orderv1.OrderListRequest) (*orderv1.OrderListResponse, error) {
-	orders, err := g.store.ListOrdersByUserID(ctx, in.UserId)
+	userID, err := ordersOf(ctx, in.UserId)
 	if err != nil {
 		return nil, err
 	}
+	orders, err := g.store.ListOrdersByUserID(ctx, userID)
+	if err != nil {
+		return nil, storeError(err, "list orders")
+	}
 	return &orderv1.OrderListResponse{Orders: orders}, nil
 }
+
+// ownOrder loads an order the caller may see. Orders of other users are
+// reported as not found, so their ids cannot be probed.
+func (g *GRPCserver) ownOrder(ctx context.Context, orderID string) (*orderv1.Order, error) {
+	order, err := g.store.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, storeError(err, "get order")
+	}
+	_, err = ordersOf(ctx, order.UserId)
+	if status.Code(err) == codes.PermissionDenied {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ordersOf returns whose orders the caller acts on. Users act on their own
+// orders, whatever the request says; staff, admins and service accounts
+// may name another user.
+func ordersOf(ctx context.Context, requested string) (string, error) {
+	p, ok := interceptor.PrincipalFrom(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	if p.ServiceAccount != "" || p.HasRole("staff", "admin") {
+		if requested != "" {
+			return requested, nil
+		}
+		if p.ServiceAccount != "" {
+			return "", status.Error(codes.InvalidArgument, "user_id is required")
+		}
+	}
+
+	own := strconv.FormatInt(p.UserID, 10)
+	if requested != "" && requested != own {
+		return "", status.Error(codes.PermissionDenied, "cannot access orders of another user")
+	}
+	return own, nil
+}
+
+// storeError turns a storage error into a status. Failures of the
+// inventory service keep their code; anything else unexpected is reported
+// as Internal without its details.
+func storeError(err error, op string) error {
+	switch {
+	case errors.Is(err, storage.ErrOrderNotFound):
+		return status.Error(codes.NotFound, "order not found")
+	case errors.Is(err, storage.ErrProductNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrInvalidProductID):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrInsufficientStock):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+			return status.Error(st.Code(), "inventory service unavailable")
+		}
+	}
+	return status.Error(codes.Internal, op)
+}
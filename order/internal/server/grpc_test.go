@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/barcek2281/comics-store/order/internal/storage"
+	"github.com/barcek2281/comics-store/order/migrations"
+	"github.com/barcek2281/comics-store/pkg/interceptor"
+	"github.com/barcek2281/comics-store/pkg/migrator"
+	orderv1 "github.com/barcek2281/proto/gen/go/order"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server whose store holds order "o1" of user 1
+// and order "o2" of user 2.
+func newTestServer(t *testing.T) *GRPCserver {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "order.db")
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.New(db, migrations.FS).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range [][2]string{{"o1", "1"}, {"o2", "2"}} {
+		_, err := db.Exec(`INSERT INTO orders (id, user_id, total_price, status, created_at) VALUES (?, ?, 10, 'created', '')`, o[0], o[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	// The inventory service is only called when orders are created.
+	store, err := storage.NewStorage(path, "passthrough:///inventory.invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewGRPCserver(store, "http://producer.invalid", time.Second)
+}
+
+var (
+	customer = interceptor.Principal{UserID: 1, Roles: []string{"customer"}}
+	staff    = interceptor.Principal{UserID: 3, Roles: []string{"staff"}}
+	service  = interceptor.Principal{ServiceAccount: "reports", Roles: []string{"service"}, Scopes: []string{"orders:read"}}
+)
+
+func TestListOrdersOwnership(t *testing.T) {
+	g := newTestServer(t)
+
+	tests := []struct {
+		name      string
+		principal interceptor.Principal
+		userID    string
+		wantCode  codes.Code
+		wantOrder string
+	}{
+		{"own orders", customer, "1", codes.OK, "o1"},
+		{"defaults to the caller", customer, "", codes.OK, "o1"},
+		{"foreign user id", customer, "2", codes.PermissionDenied, ""},
+		{"staff may name a user", staff, "2", codes.OK, "o2"},
+		{"service may name a user", service, "2", codes.OK, "o2"},
+		{"service must name a user", service, "", codes.InvalidArgument, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := interceptor.WithPrincipal(context.Background(), tt.principal)
+			res, err := g.ListOrders(ctx, &orderv1.OrderListRequest{UserId: tt.userID})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("ListOrders() error = %v, want %v", err, tt.wantCode)
+			}
+			if err == nil && (len(res.Orders) != 1 || res.Orders[0].Id != tt.wantOrder) {
+				t.Errorf("ListOrders() = %v, want only %s", res.Orders, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestForeignUserIDIsRejected(t *testing.T) {
+	g := newTestServer(t)
+	ctx := interceptor.WithPrincipal(context.Background(), customer)
+
+	if _, err := g.CloseOrder(ctx, &orderv1.CloseOrderRequest{UserId: "2"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("CloseOrder(foreign) error = %v, want PermissionDenied", err)
+	}
+	if _, err := g.DeleteOrder(ctx, &orderv1.DeleteOrderRequest{UserId: "2"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("DeleteOrder(foreign) error = %v, want PermissionDenied", err)
+	}
+	_, err := g.CreateOrder(ctx, &orderv1.CreateOrderRequest{
+		UserId: "2",
+		Items:  []*orderv1.OrderItem{{ProductId: "1", Quantity: 1}},
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("CreateOrder(foreign) error = %v, want PermissionDenied", err)
+	}
+
+	staffCtx := interceptor.WithPrincipal(context.Background(), staff)
+	other, err := g.GetOrder(staffCtx, &orderv1.GetOrderRequest{OrderId: "o2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Status != "created" {
+		t.Errorf("order o2 has status %q after rejected calls, want created", other.Status)
+	}
+}
+
+func TestOrderOwnership(t *testing.T) {
+	g := newTestServer(t)
+	ctx := interceptor.WithPrincipal(context.Background(), customer)
+
+	if _, err := g.GetOrder(ctx, &orderv1.GetOrderRequest{OrderId: "o1"}); err != nil {
+		t.Errorf("GetOrder(own) error = %v", err)
+	}
+	if _, err := g.GetOrder(ctx, &orderv1.GetOrderRequest{OrderId: "o2"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOrder(foreign) error = %v, want NotFound", err)
+	}
+	if _, err := g.UpdateOrder(ctx, &orderv1.GetOrderRequest{OrderId: "o2"}); status.Code(err) != codes.NotFound {
+		t.Errorf("UpdateOrder(foreign) error = %v, want NotFound", err)
+	}
+	if _, err := g.UpdateOrder(ctx, &orderv1.GetOrderRequest{OrderId: "o1"}); err != nil {
+		t.Errorf("UpdateOrder(own) error = %v", err)
+	}
+
+	staffCtx := interceptor.WithPrincipal(context.Background(), staff)
+	if _, err := g.UpdateOrder(staffCtx, &orderv1.GetOrderRequest{OrderId: "o2"}); err != nil {
+		t.Errorf("UpdateOrder() by staff error = %v", err)
+	}
+	if _, err := g.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderId: "o1"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetOrder() without a principal error = %v, want Unauthenticated", err)
+	}
+}
@@ -3,40 +3,60 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
 
+	"github.com/barcek2281/comics-store/pkg/requestid"
 	inventoryv1 "github.com/barcek2281/proto/gen/go/inventory"
 	orderv1 "github.com/barcek2281/proto/gen/go/order"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrProductNotFound    = errors.New("comic not found")
+	ErrInvalidProductID   = errors.New("invalid product id")
+	ErrInsufficientStock  = errors.New("not enough stock")
+	ErrDuplicateReference = errors.New("client reference already used")
 )
 
 type Storage struct {
 	db              *sql.DB
+	conn            *grpc.ClientConn
 	InventoryCLient inventoryv1.InventoryClient
 }
 
-func NewStorage(storagePath string) (*Storage, error) {
-	conn, err := grpc.NewClient(fmt.Sprintf("inventory:%s", "50052"), grpc.WithInsecure())
+func NewStorage(storagePath, inventoryAddr string) (*Storage, error) {
+	const op = "storage.sqlite.New"
+
+	conn, err := grpc.NewClient(inventoryAddr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor))
 	if err != nil {
-		return nil, nil
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	client := inventoryv1.NewInventoryClient(conn)
 
-	const op = "storage.sqlite.New"
-
 	db, err := sql.Open("sqlite3", storagePath)
 	if err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return &Storage{
-		db: db,
+		db:              db,
+		conn:            conn,
 		InventoryCLient: client,
-		}, nil
+	}, nil
+}
+
+// Close closes the database and the connection to the inventory service.
+func (s *Storage) Close() error {
+	return errors.Join(s.db.Close(), s.conn.Close())
 }
 
 func (s *Storage) CreateOrder(ctx context.Context, order *orderv1.Order) error {
@@ -46,26 +66,38 @@ func (s *Storage) CreateOrder(ctx context.Context, order *orderv1.Order) error {
 	}
 
 	for _, item := range order.Items {
-		n, _ := strconv.Atoi(item.ProductId)
-		comics, err := s.InventoryCLient.Get(ctx, &inventoryv1.GetRequest{Id: int64(n)})
+		n, err := strconv.ParseInt(item.ProductId, 10, 64)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%w: %q", ErrInvalidProductID, item.ProductId)
+		}
+		comics, err := s.InventoryCLient.Get(ctx, &inventoryv1.GetRequest{Id: n})
+		if status.Code(err) == codes.NotFound {
+			tx.Rollback()
+			return fmt.Errorf("%w: id %s", ErrProductNotFound, item.ProductId)
+		}
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
-		slog.Info("info", "item quantity", item.Quantity, "avaible", comics.Quantity)
+		slog.InfoContext(ctx, "info", "item quantity", item.Quantity, "avaible", comics.Quantity)
 		if item.Quantity > comics.Quantity {
 			tx.Rollback()
-			slog.Error("cannot fit with items", "item quantity", item.Quantity, "avaible", comics.Quantity)
+			slog.ErrorContext(ctx, "cannot fit with items", "item quantity", item.Quantity, "avaible", comics.Quantity)
 
-			return fmt.Errorf("not enough stock for comic id %s", item.ProductId)
+			return fmt.Errorf("%w for comic id %s", ErrInsufficientStock, item.ProductId)
 		}
 	}
 
 	_, err = tx.ExecContext(ctx,
-		`INSERT INTO orders (id, user_id, total_price, status, created_at)
-		 VALUES (?, ?, ?, ?, ?)`,
-		order.Id, order.UserId, order.TotalPrice, order.Status, order.CreatedAt,
+		`INSERT INTO orders (id, user_id, total_price, status, created_at, client_reference)
+		 VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))`,
+		order.Id, order.UserId, order.TotalPrice, order.Status, order.CreatedAt, order.ClientReference,
 	)
+	if isUniqueViolation(err) {
+		tx.Rollback()
+		return ErrDuplicateReference
+	}
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -79,7 +111,7 @@ func (s *Storage) CreateOrder(ctx context.Context, order *orderv1.Order) error {
 		)
 		if err != nil {
 			tx.Rollback()
-			slog.Error("error write to db", "error", err)
+			slog.ErrorContext(ctx, "error write to db", "error", err)
 
 			return err
 		}
@@ -88,12 +120,29 @@ func (s *Storage) CreateOrder(ctx context.Context, order *orderv1.Order) error {
 	return tx.Commit()
 }
 
+// OrderIDByReference returns the id of the user's order created with the
+// given client reference.
+func (s *Storage) OrderIDByReference(ctx context.Context, userID, reference string) (string, error) {
+	var id string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id FROM orders WHERE user_id = ? AND client_reference = ?`,
+		userID, reference,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrOrderNotFound
+	}
+	return id, err
+}
+
 func (s *Storage) GetOrderByID(ctx context.Context, orderID string) (*orderv1.Order, error) {
 	order := &orderv1.Order{}
 	err := s.db.QueryRowContext(ctx,
-		`SELECT id, user_id, total_price, status, created_at FROM orders WHERE id = ?`,
+		`SELECT id, user_id, total_price, status, created_at, COALESCE(client_reference, '') FROM orders WHERE id = ?`,
 		orderID,
-	).Scan(&order.Id, &order.UserId, &order.TotalPrice, &order.Status, &order.CreatedAt)
+	).Scan(&order.Id, &order.UserId, &order.TotalPrice, &order.Status, &order.CreatedAt, &order.ClientReference)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -119,11 +168,21 @@ func (s *Storage) GetOrderByID(ctx context.Context, orderID string) (*orderv1.Or
 }
 
 func (s *Storage) UpdateOrderStatus(ctx context.Context, orderID string, status string) error {
-	_, err := s.db.ExecContext(ctx,
+	res, err := s.db.ExecContext(ctx,
 		`UPDATE orders SET status = ? WHERE id = ?`,
 		status, orderID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
 }
 
 func (s *Storage) CloseOrderByUserID(ctx context.Context, userID string) error {
@@ -143,7 +202,7 @@ func (s *Storage) DeleteOrderByUserID(ctx context.Context, userID string) error
 	rows, err := tx.QueryContext(ctx, `SELECT id FROM orders WHERE user_id = ?`, userID)
 	if err != nil {
 		tx.Rollback()
-		slog.Error("error to find", "error", err)
+		slog.ErrorContext(ctx, "error to find", "error", err)
 
 		return err
 	}
@@ -163,7 +222,7 @@ func (s *Storage) DeleteOrderByUserID(ctx context.Context, userID string) error
 		_, err = tx.ExecContext(ctx, `DELETE FROM order_items WHERE order_id = ?`, id)
 		if err != nil {
 			tx.Rollback()
-			slog.Error("error to delete", "error", err)
+			slog.ErrorContext(ctx, "error to delete", "error", err)
 
 			return err
 		}
@@ -172,7 +231,7 @@ func (s *Storage) DeleteOrderByUserID(ctx context.Context, userID string) error
 	_, err = tx.ExecContext(ctx, `DELETE FROM orders WHERE user_id = ?`, userID)
 	if err != nil {
 		tx.Rollback()
-		slog.Error("error to delete", "error", err)
+		slog.ErrorContext(ctx, "error to delete", "error", err)
 
 		return err
 	}
@@ -181,7 +240,7 @@ func (s *Storage) DeleteOrderByUserID(ctx context.Context, userID string) error
 }
 func (s *Storage) ListOrdersByUserID(ctx context.Context, userID string) ([]*orderv1.Order, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, total_price, status, created_at FROM orders WHERE user_id = ?`,
+		`SELECT id, total_price, status, created_at, COALESCE(client_reference, '') FROM orders WHERE user_id = ?`,
 		userID,
 	)
 	if err != nil {
@@ -192,7 +251,7 @@ func (s *Storage) ListOrdersByUserID(ctx context.Context, userID string) ([]*ord
 	var orders []*orderv1.Order
 	for rows.Next() {
 		order := &orderv1.Order{UserId: userID}
-		err := rows.Scan(&order.Id, &order.TotalPrice, &order.Status, &order.CreatedAt)
+		err := rows.Scan(&order.Id, &order.TotalPrice, &order.Status, &order.CreatedAt, &order.ClientReference)
 		if err != nil {
 			return nil, err
 		}
@@ -220,3 +279,8 @@ func (s *Storage) ListOrdersByUserID(ctx context.Context, userID string) ([]*ord
 
 	return orders, nil
 }
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNewStorageBadInventoryAddr(t *testing.T) {
+	s, err := NewStorage(filepath.Join(t.TempDir(), "order.db"), "dns:///inventory:%zz")
+	if err == nil || s != nil {
+		t.Fatalf("NewStorage() = %v, %v; want an error", s, err)
+	}
+}
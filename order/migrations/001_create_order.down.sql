@@ -1,3 +1,2 @@
-DROP TABLE IF NOT EXISTS comics;
-DROP TABLE IF NOT EXISTS order_items;
-DROP TABLE IF NOT EXISTS orders;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
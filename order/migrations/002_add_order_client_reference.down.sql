@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orders_user_client_reference;
+ALTER TABLE orders DROP COLUMN client_reference;
@@ -0,0 +1,4 @@
+ALTER TABLE orders ADD COLUMN client_reference TEXT; -- Caller-chosen reference, unique per user
+
+CREATE UNIQUE INDEX orders_user_client_reference ON orders (user_id, client_reference)
+  WHERE client_reference IS NOT NULL;
@@ -0,0 +1,117 @@
+// Package clientip works out the address of the end client of a request.
+// Forwarded addresses (X-Forwarded-For, or the x-forwarded-for metadata
+// the gateway sends) are only believed when the request came from a
+// trusted proxy, so a client cannot pick the address it is rate limited
+// or locked out by.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MetadataKey is the gRPC metadata key the gateway forwards the client
+// address in.
+const MetadataKey = "x-forwarded-for"
+
+type ctxKey struct{}
+
+// Resolver resolves client addresses behind a set of trusted proxies.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// New parses the trusted proxies, given as CIDRs or single addresses.
+func New(trusted []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, s := range trusted {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		r.trusted = append(r.trusted, n)
+	}
+	return r, nil
+}
+
+// Resolve returns the client address of a request that arrived from remote
+// (an address with or without a port) carrying the given forwarded values.
+// The forwarded chain is only consulted when remote is trusted; it is then
+// walked from the right, and the first address that is not a trusted proxy
+// is the client.
+func (r *Resolver) Resolve(remote string, forwarded []string) string {
+	ip := host(remote)
+	if !r.Trusted(ip) {
+		return ip
+	}
+
+	var chain []string
+	for _, v := range forwarded {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				chain = append(chain, hop)
+			}
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := host(chain[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !r.Trusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// Trusted reports whether ip belongs to a trusted proxy.
+func (r *Resolver) Trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range r.trusted {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithIP returns a copy of ctx carrying the client address.
+func WithIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ip)
+}
+
+// FromContext returns the client address stored by WithIP, or "".
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ctxKey{}).(string)
+	return ip
+}
+
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return strings.Trim(addr, "[]")
+}
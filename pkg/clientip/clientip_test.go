@@ -0,0 +1,60 @@
+package clientip
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	r, err := New([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer cannot spoof", "203.0.113.7:5000", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", []string{"198.51.100.9"}, "198.51.100.9"},
+		{"single trusted address", "192.168.1.1:5000", []string{"198.51.100.9"}, "198.51.100.9"},
+		{"spoofed prefix is skipped", "10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.9"}, "198.51.100.9"},
+		{"chain of proxies", "10.0.0.2:5000", []string{"198.51.100.9, 10.0.0.3"}, "198.51.100.9"},
+		{"several headers", "10.0.0.2:5000", []string{"198.51.100.9", "10.0.0.3"}, "198.51.100.9"},
+		{"only proxies", "10.0.0.2:5000", []string{"10.0.0.3"}, "10.0.0.3"},
+		{"garbage stops the walk", "10.0.0.2:5000", []string{"198.51.100.9, nonsense"}, "10.0.0.2"},
+		{"trusted proxy without header", "10.0.0.2:5000", nil, "10.0.0.2"},
+		{"remote without port", "203.0.113.7", nil, "203.0.113.7"},
+		{"ipv6 remote", "[2001:db8::1]:5000", []string{"1.2.3.4"}, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Resolve(tt.remote, tt.forwarded); got != tt.want {
+				t.Fatalf("Resolve(%q, %q) = %q, want %q", tt.remote, tt.forwarded, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"cidrs and addresses", []string{"10.0.0.0/8", "::1", " 172.16.0.0/12 "}, false},
+		{"blank entries", []string{""}, false},
+		{"bad cidr", []string{"10.0.0.0/99"}, true},
+		{"bad address", []string{"gateway"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.trusted); (err != nil) != tt.wantErr {
+				t.Fatalf("New(%q) error = %v, wantErr %v", tt.trusted, err, tt.wantErr)
+			}
+		})
+	}
+}
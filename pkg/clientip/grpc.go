@@ -0,0 +1,21 @@
+package clientip
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryServerInterceptor resolves the client address from the transport
+// peer and, when the peer is a trusted proxy, the forwarded metadata, and
+// stores it in the handler's context.
+func (r *Resolver) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = p.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return handler(WithIP(ctx, r.Resolve(remote, md.Get(MetadataKey))), req)
+}
@@ -0,0 +1,19 @@
+module github.com/barcek2281/comics-store/pkg
+
+go 1.22.2
+
+require (
+	github.com/barcek2281/proto v0.0.0-00010101000000-000000000000
+	github.com/mattn/go-sqlite3 v1.14.27
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f
+	google.golang.org/grpc v1.71.1
+)
+
+require (
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
+)
+
+replace github.com/barcek2281/proto => ../proto
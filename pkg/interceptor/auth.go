@@ -0,0 +1,186 @@
+package interceptor
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type ctxKey string
+
+const principalKey ctxKey = "principal"
+
+// Principal is the caller identity resolved by the auth service. Calls
+// made with an API key have a ServiceAccount and Scopes instead of a user.
+// MFA is set for users whose session was opened with the second factor.
+type Principal struct {
+	UserID         int64
+	Email          string
+	ServiceAccount string
+	Roles          []string
+	Scopes         []string
+	MFA            bool
+}
+
+// Auth authenticates incoming calls by introspecting the bearer token sent
+// in the "authorization" metadata, or the API key sent in "x-api-key".
+type Auth struct {
+	client authv1.AuthClient
+	public map[string]bool
+	roles  map[string][]string
+	scopes map[string]string
+	mfa    map[string]bool
+}
+
+// NewAuth builds the interceptor. Methods listed in public need no token,
+// methods in roles need one of the given roles, everything else needs any
+// valid token. API keys may only call the methods listed in scopes, and
+// only if they were granted the scope given there. Users may only call the
+// methods listed in mfa with a token obtained with the second factor.
+func NewAuth(client authv1.AuthClient, public []string, roles map[string][]string, scopes map[string]string, mfa []string) *Auth {
+	return &Auth{
+		client: client,
+		public: set(public),
+		roles:  roles,
+		scopes: scopes,
+		mfa:    set(mfa),
+	}
+}
+
+func set(methods []string) map[string]bool {
+	s := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		s[m] = true
+	}
+	return s
+}
+
+func (a *Auth) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if a.public[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		var (
+			p   Principal
+			err error
+		)
+		if key := metadataValue(ctx, "x-api-key"); key != "" {
+			p, err = a.apiKeyPrincipal(ctx, key, info.FullMethod)
+		} else {
+			p, err = a.tokenPrincipal(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if required, ok := a.roles[info.FullMethod]; ok && !hasRole(p.Roles, required) {
+			return nil, status.Error(codes.PermissionDenied, "missing role")
+		}
+		if a.mfa[info.FullMethod] && p.ServiceAccount == "" && !p.MFA {
+			return nil, status.Error(codes.PermissionDenied, "two-factor authentication required")
+		}
+
+		return handler(WithPrincipal(ctx, p), req)
+	}
+}
+
+// HasRole reports whether the principal has any of the given roles.
+func (p Principal) HasRole(roles ...string) bool {
+	return hasRole(p.Roles, roles)
+}
+
+// WithPrincipal returns a copy of ctx that carries p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFrom returns the caller that Auth resolved for the call.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
+
+func (a *Auth) tokenPrincipal(ctx context.Context) (Principal, error) {
+	token := strings.TrimPrefix(metadataValue(ctx, "authorization"), "Bearer ")
+	if token == "" {
+		return Principal{}, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	res, err := a.client.Introspect(ctx, &authv1.IntrospectRequest{Token: token})
+	if err != nil {
+		return Principal{}, authError(ctx, err)
+	}
+	if !res.Active {
+		return Principal{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return Principal{
+		UserID: res.UserId,
+		Email:  res.Email,
+		Roles:  res.Roles,
+		MFA:    res.Mfa,
+	}, nil
+}
+
+func (a *Auth) apiKeyPrincipal(ctx context.Context, key, method string) (Principal, error) {
+	res, err := a.client.IntrospectAPIKey(ctx, &authv1.IntrospectAPIKeyRequest{Key: key})
+	if err != nil {
+		return Principal{}, authError(ctx, err)
+	}
+	if !res.Active {
+		return Principal{}, status.Error(codes.Unauthenticated, "invalid api key")
+	}
+
+	required, ok := a.scopes[method]
+	if !ok || !hasRole(res.Scopes, []string{required}) {
+		return Principal{}, status.Error(codes.PermissionDenied, "missing scope")
+	}
+
+	return Principal{
+		ServiceAccount: res.ServiceAccount,
+		Roles:          res.Roles,
+		Scopes:         res.Scopes,
+	}, nil
+}
+
+// authError reports a failed call to the auth service. Statuses the auth
+// service returned are passed on; only a service that cannot be reached is
+// reported as Unavailable, with the cause logged.
+func authError(ctx context.Context, err error) error {
+	st, ok := status.FromError(err)
+	if ok && st.Code() != codes.Unavailable {
+		return st.Err()
+	}
+	slog.WarnContext(ctx, "cannot reach auth service", "error", err)
+	return status.Error(codes.Unavailable, "cannot reach auth service")
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func hasRole(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
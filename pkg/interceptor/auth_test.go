@@ -0,0 +1,92 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	authv1 "github.com/barcek2281/proto/gen/go/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAuth answers introspection with fixed responses.
+type fakeAuth struct {
+	authv1.AuthClient
+	token  *authv1.IntrospectResponse
+	apiKey *authv1.IntrospectAPIKeyResponse
+	err    error
+}
+
+func (f *fakeAuth) Introspect(ctx context.Context, in *authv1.IntrospectRequest, opts ...grpc.CallOption) (*authv1.IntrospectResponse, error) {
+	return f.token, f.err
+}
+
+func (f *fakeAuth) IntrospectAPIKey(ctx context.Context, in *authv1.IntrospectAPIKeyRequest, opts ...grpc.CallOption) (*authv1.IntrospectAPIKeyResponse, error) {
+	return f.apiKey, f.err
+}
+
+func TestAuthUnary(t *testing.T) {
+	customer := &authv1.IntrospectResponse{Active: true, UserId: 7, Roles: []string{"customer"}}
+	tests := []struct {
+		name     string
+		client   *fakeAuth
+		method   string
+		md       metadata.MD
+		wantCode codes.Code
+		wantUser int64
+	}{
+		{"public method", &fakeAuth{}, "/svc/Public", nil, codes.OK, 0},
+		{"missing token", &fakeAuth{}, "/svc/Read", nil, codes.Unauthenticated, 0},
+		{"valid token", &fakeAuth{token: customer}, "/svc/Read", bearer("t"), codes.OK, 7},
+		{"inactive token", &fakeAuth{token: &authv1.IntrospectResponse{}}, "/svc/Read", bearer("t"), codes.Unauthenticated, 0},
+		{"missing role", &fakeAuth{token: customer}, "/svc/Write", bearer("t"), codes.PermissionDenied, 0},
+		{"auth service down", &fakeAuth{err: status.Error(codes.Unavailable, "connection refused")}, "/svc/Read", bearer("t"), codes.Unavailable, 0},
+		{"auth rejects the request", &fakeAuth{err: status.Error(codes.InvalidArgument, "token is required")}, "/svc/Read", bearer("t"), codes.InvalidArgument, 0},
+		{"auth times out", &fakeAuth{err: status.Error(codes.DeadlineExceeded, "deadline exceeded")}, "/svc/Read", bearer("t"), codes.DeadlineExceeded, 0},
+		{"api key with scope", &fakeAuth{apiKey: &authv1.IntrospectAPIKeyResponse{Active: true, Roles: []string{"service"}, Scopes: []string{"read"}}}, "/svc/Read", apiKey("k"), codes.OK, 0},
+		{"api key without scope", &fakeAuth{apiKey: &authv1.IntrospectAPIKeyResponse{Active: true, Roles: []string{"service"}}}, "/svc/Read", apiKey("k"), codes.PermissionDenied, 0},
+		{"password-only token", &fakeAuth{token: customer}, "/svc/Secure", bearer("t"), codes.PermissionDenied, 0},
+		{"token with second factor", &fakeAuth{token: &authv1.IntrospectResponse{Active: true, UserId: 7, Mfa: true}}, "/svc/Secure", bearer("t"), codes.OK, 7},
+		{"api key needs no second factor", &fakeAuth{apiKey: &authv1.IntrospectAPIKeyResponse{Active: true, ServiceAccount: "reports", Scopes: []string{"write"}}}, "/svc/Secure", apiKey("k"), codes.OK, 0},
+		{"api key error", &fakeAuth{err: status.Error(codes.Internal, "api key lookup")}, "/svc/Read", apiKey("k"), codes.Internal, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuth(tt.client, []string{"/svc/Public"}, map[string][]string{"/svc/Write": {"staff"}}, map[string]string{"/svc/Read": "read", "/svc/Secure": "write"}, []string{"/svc/Secure"})
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			var got Principal
+			_, err := a.Unary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				got, _ = PrincipalFrom(ctx)
+				return nil, nil
+			})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("Unary() error = %v, want %v", err, tt.wantCode)
+			}
+			if got.UserID != tt.wantUser {
+				t.Errorf("principal user = %d, want %d", got.UserID, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestPrincipalHasRole(t *testing.T) {
+	p := Principal{Roles: []string{"customer", "staff"}}
+	if !p.HasRole("admin", "staff") {
+		t.Error("HasRole(admin, staff) = false, want true")
+	}
+	if p.HasRole("admin") {
+		t.Error("HasRole(admin) = true, want false")
+	}
+}
+
+func bearer(token string) metadata.MD {
+	return metadata.Pairs("authorization", "Bearer "+token)
+}
+
+func apiKey(key string) metadata.MD {
+	return metadata.Pairs("x-api-key", key)
+}
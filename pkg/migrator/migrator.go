@@ -0,0 +1,257 @@
+// Package migrator applies the SQL migrations embedded in the binary and
+// records them in the schema_history table.
+//
+// Migrations are files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Each one runs in its own transaction together
+// with its bookkeeping row.
+//
+// Databases created before the services embedded their migrations already
+// hold the tables of the first migration, and those of inventory and order
+// carry a schema_migrations table written by the golang-migrate CLI. The
+// first migration of every service therefore only creates what is missing,
+// and the bookkeeping table has a name of its own.
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes one known migration and whether it is applied.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db   *sql.DB
+	fsys fs.FS
+}
+
+func New(db *sql.DB, fsys fs.FS) *Migrator {
+	return &Migrator{db: db, fsys: fsys}
+}
+
+// Up applies every pending migration in version order and returns how many
+// it applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, mig := range migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if mig.Up == "" {
+			return n, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		err := m.apply(ctx, mig.Up, `INSERT INTO schema_history(version, name, applied_at) VALUES (?, ?, ?)`,
+			mig.Version, mig.Name, time.Now().Unix())
+		if err != nil {
+			return n, fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+		}
+		slog.Info("applied migration", "version", mig.Version, "name", mig.Name)
+		n++
+	}
+	return n, nil
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for i := len(migrations) - 1; i >= 0 && n < steps; i-- {
+		mig := migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return n, fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+		}
+		err := m.apply(ctx, mig.Down, `DELETE FROM schema_history WHERE version = ?`, mig.Version)
+		if err != nil {
+			return n, fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+		}
+		slog.Info("rolled back migration", "version", mig.Version, "name", mig.Name)
+		n++
+	}
+	return n, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Status, 0, len(migrations))
+	for _, mig := range migrations {
+		at, ok := applied[mig.Version]
+		out = append(out, Status{Version: mig.Version, Name: mig.Name, Applied: ok, AppliedAt: at})
+	}
+	return out, nil
+}
+
+// Run executes a migrate subcommand: "up", "down [steps]" or "status".
+// Output meant for the operator goes to w.
+func (m *Migrator) Run(ctx context.Context, w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		fmt.Fprintf(w, "applied %d migration(s)\n", n)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = v
+		}
+		n, err := m.Down(ctx, steps)
+		fmt.Fprintf(w, "rolled back %d migration(s)\n", n)
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, at := "pending", ""
+			if s.Applied {
+				state, at = "applied", s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func (m *Migrator) apply(ctx context.Context, script, bookkeeping string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// load reads the embedded migrations sorted by version and the versions
+// already applied, with their time.
+func (m *Migrator) load(ctx context.Context) ([]Migration, map[int64]time.Time, error) {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_history (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create schema_history: %w", err)
+	}
+
+	migrations, err := m.read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_history`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version, at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, nil, err
+		}
+		applied[version] = time.Unix(at, 0)
+	}
+	return migrations, applied, rows.Err()
+}
+
+func (m *Migrator) read() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, mig.Name, match[2])
+		}
+
+		body, err := fs.ReadFile(m.fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", e.Name(), err)
+		}
+		if match[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		out = append(out, *mig)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
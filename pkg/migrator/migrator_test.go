@@ -0,0 +1,225 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testFS = fstest.MapFS{
+	"001_create_users.up.sql":      {Data: []byte("CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);")},
+	"001_create_users.down.sql":    {Data: []byte("DROP TABLE users;")},
+	"002_add_roles.up.sql":         {Data: []byte("ALTER TABLE users ADD COLUMN roles TEXT NOT NULL DEFAULT '';")},
+	"002_add_roles.down.sql":       {Data: []byte("ALTER TABLE users DROP COLUMN roles;")},
+	"003_create_sessions.up.sql":   {Data: []byte("CREATE TABLE sessions (id TEXT PRIMARY KEY);")},
+	"003_create_sessions.down.sql": {Data: []byte("DROP TABLE sessions;")},
+	"README.md":                    {Data: []byte("not a migration")},
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func applied(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []int64
+	for _, s := range statuses {
+		if s.Applied {
+			out = append(out, s.Version)
+		}
+	}
+	return out
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	m := New(openDB(t), testFS)
+
+	steps := []struct {
+		name        string
+		run         func() (int, error)
+		wantN       int
+		wantApplied []int64
+	}{
+		{"up applies everything", func() (int, error) { return m.Up(ctx) }, 3, []int64{1, 2, 3}},
+		{"up again is a no-op", func() (int, error) { return m.Up(ctx) }, 0, []int64{1, 2, 3}},
+		{"down rolls back the newest", func() (int, error) { return m.Down(ctx, 1) }, 1, []int64{1, 2}},
+		{"down stops at the oldest", func() (int, error) { return m.Down(ctx, 5) }, 2, nil},
+		{"up reapplies", func() (int, error) { return m.Up(ctx) }, 3, []int64{1, 2, 3}},
+	}
+	for _, step := range steps {
+		n, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if n != step.wantN {
+			t.Errorf("%s: ran %d migrations, want %d", step.name, n, step.wantN)
+		}
+		if got := applied(t, m); !equal(got, step.wantApplied) {
+			t.Errorf("%s: applied %v, want %v", step.name, got, step.wantApplied)
+		}
+	}
+}
+
+func TestUpOnExistingDatabase(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		setup string
+	}{
+		{"tables created by hand", "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);"},
+		{
+			"golang-migrate bookkeeping",
+			`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);
+			CREATE TABLE schema_migrations (version uint64 NOT NULL PRIMARY KEY, dirty bool NOT NULL);
+			INSERT INTO schema_migrations VALUES (1, 0);`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDB(t)
+			if _, err := db.Exec(tt.setup); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec("INSERT INTO users(email) VALUES ('a@example.com')"); err != nil {
+				t.Fatal(err)
+			}
+
+			m := New(db, testFS)
+			if _, err := m.Up(ctx); err != nil {
+				t.Fatalf("Up() on an existing database: %v", err)
+			}
+			if got := applied(t, m); !equal(got, []int64{1, 2, 3}) {
+				t.Errorf("applied %v, want [1 2 3]", got)
+			}
+
+			var n int
+			if err := db.QueryRow("SELECT COUNT(*) FROM users WHERE roles = ''").Scan(&n); err != nil || n != 1 {
+				t.Errorf("existing rows were not kept: n = %d, err = %v", n, err)
+			}
+		})
+	}
+}
+
+func TestUpFailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"001_ok.up.sql":     {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"002_broken.up.sql": {Data: []byte("CREATE TABLE b (id INTEGER); INSERT INTO missing VALUES (1);")},
+	}
+	db := openDB(t)
+	m := New(db, fsys)
+
+	n, err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "2_broken up") {
+		t.Fatalf("Up() error = %v, want a failure of 002_broken", err)
+	}
+	if n != 1 {
+		t.Errorf("Up() applied %d migrations, want 1", n)
+	}
+	if got := applied(t, m); !equal(got, []int64{1}) {
+		t.Errorf("applied %v, want [1]", got)
+	}
+	if _, err := db.Exec("SELECT * FROM b"); err == nil {
+		t.Error("table b of the failed migration exists")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		run  func(m *Migrator) error
+		want string
+	}{
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"001_a.up.sql": {Data: []byte("SELECT 1;")},
+				"001_b.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			run:  func(m *Migrator) error { _, err := m.Up(context.Background()); return err },
+			want: "is used by",
+		},
+		{
+			name: "missing up file",
+			fsys: fstest.MapFS{"001_a.down.sql": {Data: []byte("SELECT 1;")}},
+			run:  func(m *Migrator) error { _, err := m.Up(context.Background()); return err },
+			want: "has no up file",
+		},
+		{
+			name: "unknown command",
+			fsys: testFS,
+			run:  func(m *Migrator) error { return m.Run(context.Background(), io.Discard, []string{"sideways"}) },
+			want: "unknown migrate command",
+		},
+		{
+			name: "invalid steps",
+			fsys: testFS,
+			run:  func(m *Migrator) error { return m.Run(context.Background(), io.Discard, []string{"down", "0"}) },
+			want: "invalid number of steps",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(New(openDB(t), tt.fsys))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunStatus(t *testing.T) {
+	ctx := context.Background()
+	m := New(openDB(t), testFS)
+	if err := m.Run(ctx, io.Discard, []string{"up"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := m.Run(ctx, &out, []string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("status printed %d lines, want 4:\n%s", len(lines), out.String())
+	}
+	for i, want := range []string{"applied", "applied", "pending"} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("line %q does not say %s", lines[i+1], want)
+		}
+	}
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package requestid
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor reads the id from incoming metadata, or generates
+// one for callers that sent none, and stores it in the handler's context.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(MetadataKey); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	return handler(WithID(ctx, Sanitize(id)), req)
+}
+
+// UnaryClientInterceptor forwards the id carried by ctx in the outgoing
+// metadata.
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if id := FromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,63 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want string
+	}{
+		{"forwarded id", metadata.Pairs(MetadataKey, "abc"), "abc"},
+		{"missing id", metadata.MD{}, ""},
+		{"unsafe id", metadata.Pairs(MetadataKey, "a b"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			var got string
+			_, err := UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				got = FromContext(ctx)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("handler saw id %q, want %q", got, tt.want)
+			}
+			if tt.want == "" && len(got) != 32 {
+				t.Errorf("handler saw id %q, want a generated one", got)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	invoke := func(ctx context.Context) []string {
+		var got []string
+		err := UnaryClientInterceptor(ctx, "/svc/Method", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			got = md.Get(MetadataKey)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	if got := invoke(WithID(context.Background(), "abc")); len(got) != 1 || got[0] != "abc" {
+		t.Errorf("forwarded %v, want [abc]", got)
+	}
+	if got := invoke(context.Background()); len(got) != 0 {
+		t.Errorf("forwarded %v without an id, want nothing", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Package requestid carries the id a request is known by through every
+// service it touches. The gateway accepts the caller's X-Request-ID or
+// generates one, stores it in the context and passes it on in gRPC
+// metadata, HTTP headers and NATS message headers. Loggers built with
+// NewHandler add it to every record logged with that context.
+//
+// Every service imports it from pkg so they agree on the header names and
+// log attribute.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+const (
+	// Header is the HTTP and NATS header the id travels in.
+	Header = "X-Request-ID"
+	// MetadataKey is the gRPC metadata key the id travels in.
+	MetadataKey = "x-request-id"
+	// LogKey is the attribute the id is logged as.
+	LogKey = "request_id"
+
+	maxLen = 128
+)
+
+type ctxKey struct{}
+
+// New returns a random id.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("requestid: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Sanitize returns id if it is safe to log and forward, and a new id
+// otherwise, so callers cannot inject log lines or oversized headers.
+func Sanitize(id string) string {
+	if id == "" || len(id) > maxLen {
+		return New()
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return New()
+		}
+	}
+	return id
+}
+
+// WithID returns a copy of ctx that carries id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the id ctx carries, or "".
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Handler adds the request id of the context a record was logged with.
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps h so records logged with a context carrying an id get
+// a request_id attribute.
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String(LogKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
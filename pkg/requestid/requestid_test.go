@@ -0,0 +1,70 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		keep bool
+	}{
+		{"generated id", New(), true},
+		{"uuid", "3f2b8c1e-7d4a-4b9e-9c1a-2e5f6a7b8c9d", true},
+		{"allowed punctuation", "gw_1.a:b-c", true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("a", maxLen+1), false},
+		{"newline", "abc\nlevel=ERROR msg=forged", false},
+		{"space", "abc def", false},
+		{"non-ascii", "идентификатор", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sanitize(tt.id)
+			if tt.keep {
+				if got != tt.id {
+					t.Errorf("Sanitize(%q) = %q, want it unchanged", tt.id, got)
+				}
+				return
+			}
+			if got == tt.id || len(got) != 32 {
+				t.Errorf("Sanitize(%q) = %q, want a new id", tt.id, got)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if id := FromContext(ctx); id != "" {
+		t.Errorf("FromContext() = %q without an id, want \"\"", id)
+	}
+	if id := FromContext(WithID(ctx, "abc")); id != "abc" {
+		t.Errorf("FromContext() = %q, want %q", id, "abc")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewHandler(slog.NewTextHandler(&buf, nil))).With("service", "test").WithGroup("g")
+
+	log.InfoContext(WithID(context.Background(), "abc"), "with id")
+	log.InfoContext(context.Background(), "without id")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "service=test") || !strings.Contains(lines[0], LogKey+"=abc") {
+		t.Errorf("record with id = %q, want the service attribute and %s=abc", lines[0], LogKey)
+	}
+	if strings.Contains(lines[1], LogKey) {
+		t.Errorf("record without id = %q, want no %s", lines[1], LogKey)
+	}
+}
@@ -0,0 +1,216 @@
+// Package validate checks structs against rules declared in their
+// `validate` tags, e.g.
+//
+//	Title string  `json:"title" validate:"required,max=200"`
+//	Price float64 `json:"price" validate:"min=0"`
+//
+// Supported rules:
+//
+//	required  the value must not be zero; strings must not be blank
+//	min=N     numbers must be >= N, strings at least N characters long,
+//	          slices at least N items long
+//	max=N     the upper bound counterpart of min
+//	email     a bare email address
+//	date      a calendar date in YYYY-MM-DD form
+//
+// Empty strings are only checked by required, so optional fields may be
+// left out. Nested structs and slices of structs are checked too. Fields
+// are reported by their JSON name.
+//
+// The gateway and the gRPC servers share this package, so they enforce
+// the same rules.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DateLayout is the layout the date rule accepts.
+const DateLayout = "2006-01-02"
+
+// FieldError describes why one field was rejected.
+type FieldError struct {
+	Field       string
+	Description string
+}
+
+// Errors lists every field that failed validation. It converts to an
+// InvalidArgument status with BadRequest details, so gRPC servers can
+// return it as is.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, f := range e {
+		parts[i] = f.Field + " " + f.Description
+	}
+	return strings.Join(parts, "; ")
+}
+
+// GRPCStatus lets status.FromError and the gRPC server see e as an
+// InvalidArgument status.
+func (e Errors) GRPCStatus() *status.Status {
+	st := status.New(codes.InvalidArgument, "invalid request")
+	br := &errdetails.BadRequest{}
+	for _, f := range e {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       f.Field,
+			Description: f.Description,
+		})
+	}
+	if detailed, err := st.WithDetails(br); err == nil {
+		return detailed
+	}
+	return st
+}
+
+// Struct validates v, which must be a struct or a pointer to one. It
+// returns nil or an Errors value.
+func Struct(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+
+	var errs Errors
+	checkStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkStruct(rv reflect.Value, prefix string, errs *Errors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(sf)
+		fv := rv.Field(i)
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				if msg := check(fv, tag, rule); msg != "" {
+					*errs = append(*errs, FieldError{Field: name, Description: msg})
+					break
+				}
+			}
+		}
+		descend(fv, name, errs)
+	}
+}
+
+func descend(fv reflect.Value, name string, errs *Errors) {
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if !fv.IsNil() {
+			descend(fv.Elem(), name, errs)
+		}
+	case reflect.Struct:
+		checkStruct(fv, name+".", errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			descend(fv.Index(i), fmt.Sprintf("%s[%d]", name, i), errs)
+		}
+	}
+}
+
+// check applies one rule and returns a description of the violation, or
+// "" if the value passes.
+func check(fv reflect.Value, tag, rule string) string {
+	name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+	if name == "required" {
+		if isBlank(fv) {
+			return "is required"
+		}
+		return ""
+	}
+	if fv.Kind() == reflect.String && isBlank(fv) {
+		return ""
+	}
+
+	switch name {
+	case "min", "max":
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad rule %q in tag %q", rule, tag))
+		}
+		return checkBound(fv, name, bound)
+	case "email":
+		addr, err := mail.ParseAddress(fv.String())
+		if err != nil || addr.Address != fv.String() {
+			return "must be a valid email address"
+		}
+	case "date":
+		if _, err := time.Parse(DateLayout, fv.String()); err != nil {
+			return "must be a date in YYYY-MM-DD format"
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q in tag %q", rule, tag))
+	}
+	return ""
+}
+
+func checkBound(fv reflect.Value, rule string, bound float64) string {
+	var n float64
+	var unit string
+	switch fv.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(fv.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(fv.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = fv.Float()
+	default:
+		panic(fmt.Sprintf("validate: %s cannot be bounded", fv.Type()))
+	}
+
+	limit := strconv.FormatFloat(bound, 'f', -1, 64)
+	if rule == "min" && n < bound {
+		if unit == "" {
+			return "must be at least " + limit
+		}
+		return "must have at least " + limit + unit
+	}
+	if rule == "max" && n > bound {
+		if unit == "" {
+			return "must be at most " + limit
+		}
+		return "must have at most " + limit + unit
+	}
+	return ""
+}
+
+func isBlank(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(fv.String()) == ""
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return fv.Len() == 0
+	}
+	return fv.IsZero()
+}
+
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
@@ -0,0 +1,145 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type item struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int32  `json:"quantity" validate:"min=1,max=1000"`
+}
+
+type order struct {
+	Email    string  `json:"email" validate:"required,email"`
+	Note     string  `json:"note" validate:"max=5"`
+	Date     string  `json:"date" validate:"date"`
+	Price    float64 `json:"price" validate:"min=0"`
+	Items    []item  `json:"items" validate:"required,max=2"`
+	Internal string  `validate:"max=3"`
+	Shipping *item   `json:"shipping,omitempty"`
+}
+
+func validOrder() order {
+	return order{
+		Email: "alice@example.com",
+		Date:  "2024-02-29",
+		Items: []item{{ProductID: "1", Quantity: 1}},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *order)
+		want   Errors
+	}{
+		{"valid", func(o *order) {}, nil},
+		{"optional fields left out", func(o *order) { o.Date, o.Note = "", "" }, nil},
+		{"blank required string", func(o *order) { o.Email = "  " }, Errors{{"email", "is required"}}},
+		{"invalid email", func(o *order) { o.Email = "Alice <alice@example.com>" }, Errors{{"email", "must be a valid email address"}}},
+		{"string too long", func(o *order) { o.Note = "héllo!" }, Errors{{"note", "must have at most 5 characters"}}},
+		{"length counts runes", func(o *order) { o.Note = "héllo" }, nil},
+		{"invalid date", func(o *order) { o.Date = "2023-02-29" }, Errors{{"date", "must be a date in YYYY-MM-DD format"}}},
+		{"number below min", func(o *order) { o.Price = -0.5 }, Errors{{"price", "must be at least 0"}}},
+		{"empty required slice", func(o *order) { o.Items = nil }, Errors{{"items", "is required"}}},
+		{
+			"too many items",
+			func(o *order) { o.Items = append(o.Items, o.Items[0], o.Items[0]) },
+			Errors{{"items", "must have at most 2 items"}},
+		},
+		{
+			"nested slice element",
+			func(o *order) { o.Items[0] = item{Quantity: 0} },
+			Errors{{"items[0].product_id", "is required"}, {"items[0].quantity", "must be at least 1"}},
+		},
+		{"nested pointer", func(o *order) { o.Shipping = &item{ProductID: "x", Quantity: 1001} }, Errors{{"shipping.quantity", "must be at most 1000"}}},
+		{"field without json name", func(o *order) { o.Internal = "long" }, Errors{{"Internal", "must have at most 3 characters"}}},
+		{
+			"every failing field is reported",
+			func(o *order) { o.Email, o.Price = "", -1 },
+			Errors{{"email", "is required"}, {"price", "must be at least 0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := validOrder()
+			tt.modify(&o)
+
+			err := Struct(&o)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Struct() = %v, want nil", err)
+				}
+				return
+			}
+
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("Struct() = %v, want Errors", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Struct() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("error %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestErrorsGRPCStatus(t *testing.T) {
+	err := Struct(order{Email: "nope"})
+
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.InvalidArgument {
+		t.Fatalf("status.FromError() = %v, %v, want InvalidArgument", st, ok)
+	}
+
+	var fields []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	if len(fields) != 2 || fields[0] != "email" || fields[1] != "items" {
+		t.Errorf("field violations = %v, want [email items]", fields)
+	}
+}
+
+func TestStructPanicsOnBadRules(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"not a struct", "text"},
+		{"unknown rule", struct {
+			A string `validate:"uuid"`
+		}{"x"}},
+		{"bad bound", struct {
+			A int `validate:"min=one"`
+		}{1}},
+		{"unboundable kind", struct {
+			A bool `validate:"min=1"`
+		}{true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Struct() did not panic")
+				}
+			}()
+			Struct(tt.v)
+		})
+	}
+}
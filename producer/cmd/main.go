@@ -1,15 +1,52 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/barcek2281/comics-store/pkg/requestid"
+	"log"
 	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"producer/internal/configs"
 	"producer/internal/server"
+	"syscall"
 )
 
+var (
+	configPath  string
+	printConfig bool
+)
+
+func init() {
+	flag.StringVar(&configPath, "config-path", "./configs/local.yaml", "config path")
+	flag.BoolVar(&printConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+}
+
 func main() {
-	
-	s := server.NewServer(8181)
+	flag.Parse()
+
+	cfg := configs.MustLoad(configPath)
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("cannot print config: %v", err)
+		}
+		return
+	}
+
+	level, _ := cfg.Level()
+	slog.SetDefault(slog.New(requestid.NewHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := server.NewServer(cfg.Port, cfg.NatsURL, cfg.ShutdownTimeout)
 	slog.Info("server is running")
-	if err := s.Run(); err != nil {
-		slog.Error("error to running server")
+	if err := s.Run(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("error to running server", "error", err)
+		os.Exit(1)
 	}
-}
\ No newline at end of file
+	slog.Info("server stopped")
+}
@@ -4,12 +4,29 @@ go 1.23.0
 
 toolchain go1.23.8
 
-require github.com/nats-io/nats.go v1.41.2
+require (
+	github.com/barcek2281/comics-store/pkg v0.0.0-00010101000000-000000000000
+	github.com/ilyakaznacheev/cleanenv v1.5.0
+	github.com/nats-io/nats.go v1.41.2
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
+	github.com/BurntSushi/toml v1.5.0 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/nats-io/nkeys v0.4.11 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+	google.golang.org/grpc v1.71.1 // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
+	olympos.io/encoding/edn v0.0.0-20201019073823-d3554ca0b0a3 // indirect
 )
+
+replace github.com/barcek2281/comics-store/pkg => ../pkg
+
+replace github.com/barcek2281/proto => ../proto
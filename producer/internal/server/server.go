@@ -1,13 +1,16 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"producer/internal/mdoels"
+	"time"
 
+	"github.com/barcek2281/comics-store/pkg/requestid"
 	"github.com/nats-io/nats.go"
 )
 
@@ -16,45 +19,83 @@ const (
 )
 
 type Server struct {
-	port int
-	mux  *http.ServeMux
-	nc *nats.Conn
+	port            int
+	mux             *http.ServeMux
+	nc              *nats.Conn
+	shutdownTimeout time.Duration
 }
 
-func NewServer(port int) *Server {
+func NewServer(port int, natsURL string, shutdownTimeout time.Duration) *Server {
 
-	nc, err := nats.Connect("nats://nats:4222")
+	nc, err := nats.Connect(natsURL)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	return &Server{
-		port: port,
-		mux:  http.NewServeMux(),
-		nc: nc,
+		port:            port,
+		mux:             http.NewServeMux(),
+		nc:              nc,
+		shutdownTimeout: shutdownTimeout,
 	}
 }
 
-func (s *Server) Run() error {
+// Run serves until ctx is cancelled. It then waits for in-flight requests
+// and flushes published events before closing the NATS connection.
+func (s *Server) Run(ctx context.Context) error {
 	s.mux.HandleFunc("POST /create-order", s.createOrder())
 	slog.Info(fmt.Sprintf(":%d", s.port))
-	return http.ListenAndServe(fmt.Sprintf(":%d", s.port), s.mux)
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", s.port), Handler: withRequestID(s.mux)}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+
+	if ferr := s.nc.FlushTimeout(s.shutdownTimeout); ferr != nil {
+		slog.Warn("cannot flush nats connection", "error", ferr)
+	}
+	s.nc.Close()
+	return err
 }
 
 func (s *Server) createOrder() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		var orderReq mdoels.Order
 		if err := json.NewDecoder(r.Body).Decode(&orderReq); err != nil {
-			slog.Error("cannot parse json file")
+			slog.ErrorContext(ctx, "cannot parse json file")
 			return
 		}
-		data, _ := json.Marshal(orderReq)
-		err := s.nc.Publish(orderCreated, data)
+		msg := nats.NewMsg(orderCreated)
+		msg.Data, _ = json.Marshal(orderReq)
+		msg.Header.Set(requestid.Header, requestid.FromContext(ctx))
+		err := s.nc.PublishMsg(msg)
 
 		if err != nil {
-			slog.Error("cannot publish", "error", err)
+			slog.ErrorContext(ctx, "cannot publish", "error", err)
 		}
-		
-		slog.Info("publish order.created")
+
+		slog.InfoContext(ctx, "publish order.created")
 	}
 }
+
+// withRequestID stores the request id sent by the order service in the
+// request context, so it is logged and forwarded with the event.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestid.Sanitize(r.Header.Get(requestid.Header))
+		next.ServeHTTP(w, r.WithContext(requestid.WithID(r.Context(), id)))
+	})
+}